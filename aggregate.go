@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+type aggregate interface {
+	add(s string) error
+	result() string
+}
+
+func recordKey(record []string) string {
+	a := make([]string, len(record))
+	for i, field := range record {
+		a[i] = strconv.Quote(field)
+	}
+	return strings.Join(a, ",")
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+type countAggregate struct {
+	all bool
+	n   int
+}
+
+func (a *countAggregate) add(s string) error {
+	if a.all || s != "" {
+		a.n++
+	}
+	return nil
+}
+
+func (a *countAggregate) result() string {
+	return strconv.Itoa(a.n)
+}
+
+type sumAggregate struct {
+	sum float64
+	n   int
+	avg bool
+}
+
+func (a *sumAggregate) add(s string) error {
+	if s == "" {
+		return nil
+	}
+	n, err := toNumber(s)
+	if err != nil {
+		return err
+	}
+	a.sum += n
+	a.n++
+	return nil
+}
+
+func (a *sumAggregate) result() string {
+	switch {
+	case !a.avg:
+		return formatNumber(a.sum)
+	case a.n == 0:
+		return ""
+	}
+	return formatNumber(a.sum / float64(a.n))
+}
+
+type extremeAggregate struct {
+	max   bool
+	n     float64
+	found bool
+}
+
+func (a *extremeAggregate) add(s string) error {
+	if s == "" {
+		return nil
+	}
+	n, err := toNumber(s)
+	if err != nil {
+		return err
+	}
+	if !a.found || (a.max && n > a.n) || (!a.max && n < a.n) {
+		a.n = n
+		a.found = true
+	}
+	return nil
+}
+
+func (a *extremeAggregate) result() string {
+	if !a.found {
+		return ""
+	}
+	return formatNumber(a.n)
+}
+
+type distinctAggregate struct {
+	values map[string]bool
+}
+
+func (a *distinctAggregate) add(s string) error {
+	a.values[s] = true
+	return nil
+}
+
+func (a *distinctAggregate) result() string {
+	return strconv.Itoa(len(a.values))
+}
+
+var aggregates = map[string]func(all bool) aggregate{
+	"count": func(all bool) aggregate {
+		return &countAggregate{all: all}
+	},
+	"sum": func(all bool) aggregate {
+		return &sumAggregate{}
+	},
+	"avg": func(all bool) aggregate {
+		return &sumAggregate{avg: true}
+	},
+	"min": func(all bool) aggregate {
+		return &extremeAggregate{}
+	},
+	"max": func(all bool) aggregate {
+		return &extremeAggregate{max: true}
+	},
+	"distinct": func(all bool) aggregate {
+		return &distinctAggregate{values: make(map[string]bool)}
+	},
+}
+
+var exprAggregation = regexp.MustCompile(`^(\w+)\((.*)\)$`)
+
+type Aggregation struct {
+	spec   string
+	name   string
+	header string
+	index  int
+}
+
+func parseAggregation(spec string) (*Aggregation, error) {
+	m := exprAggregation.FindStringSubmatch(spec)
+	if m == nil {
+		return nil, fmt.Errorf("%q: invalid aggregation", spec)
+	}
+	if _, ok := aggregates[m[1]]; !ok {
+		return nil, fmt.Errorf("%q: unknown aggregation", m[1])
+	}
+	if m[2] == "" && m[1] != "count" {
+		return nil, fmt.Errorf("%q: header required", spec)
+	}
+	return &Aggregation{
+		spec:   csvp.Unescape(spec),
+		name:   m[1],
+		header: csvp.Unescape(m[2]),
+	}, nil
+}
+
+func (a *Aggregation) newAggregate() aggregate {
+	return aggregates[a.name](a.header == "")
+}
+
+type group struct {
+	key        []string
+	aggregates []aggregate
+}
+
+type Aggregator struct {
+	w             Writer
+	groupBy       *csvp.Headers
+	aggregations  []*Aggregation
+	groups        map[string]*group
+	order         []*group
+	parsedHeaders bool
+}
+
+func NewAggregator(w Writer, groupBy string, list string) (*Aggregator, error) {
+	aggregations := make([]*Aggregation, 0)
+	for _, spec := range splitList(list, ',') {
+		a, err := parseAggregation(spec)
+		if err != nil {
+			return nil, err
+		}
+		aggregations = append(aggregations, a)
+	}
+	return &Aggregator{
+		w:            w,
+		groupBy:      csvp.NewHeaders(groupBy),
+		aggregations: aggregations,
+		groups:       make(map[string]*group),
+	}, nil
+}
+
+// WriteHeaders resolves the group and aggregation headers for every file,
+// since the files may order their columns differently.
+func (a *Aggregator) WriteHeaders(headers []string) error {
+	if err := a.groupBy.ParseHeaders(headers); err != nil {
+		return err
+	}
+	if err := checkHeaders(a.groupBy); err != nil {
+		return err
+	}
+	for _, aggregation := range a.aggregations {
+		aggregation.index = -1
+		if aggregation.header != "" {
+			aggregation.index = headerIndex(headers, aggregation.header)
+		}
+		if aggregation.header != "" && aggregation.index == -1 {
+			return fmt.Errorf("%q: no such header", aggregation.header)
+		}
+	}
+	if a.parsedHeaders {
+		return nil
+	}
+	a.parsedHeaders = true
+
+	outputHeaders := append([]string{}, a.groupBy.OutputHeaders()...)
+	for _, aggregation := range a.aggregations {
+		outputHeaders = append(outputHeaders, aggregation.spec)
+	}
+	return a.w.WriteHeaders(outputHeaders)
+}
+
+func (a *Aggregator) Write(record []string) error {
+	key, err := a.groupBy.Select(record)
+	if err != nil {
+		return err
+	}
+
+	k := recordKey(key)
+	g, ok := a.groups[k]
+	if !ok {
+		g = &group{key: key}
+		for _, aggregation := range a.aggregations {
+			g.aggregates = append(g.aggregates, aggregation.newAggregate())
+		}
+		a.groups[k] = g
+		a.order = append(a.order, g)
+	}
+
+	for i, aggregation := range a.aggregations {
+		var s string
+		if aggregation.index != -1 && aggregation.index < len(record) {
+			s = record[aggregation.index]
+		}
+		if err := g.aggregates[i].add(s); err != nil {
+			return fmt.Errorf("%s: %s", aggregation.spec, err)
+		}
+	}
+	return nil
+}
+
+func (a *Aggregator) Flush() error {
+	for _, g := range a.order {
+		record := append([]string{}, g.key...)
+		for _, aggregate := range g.aggregates {
+			record = append(record, aggregate.result())
+		}
+		if err := a.w.Write(record); err != nil {
+			if err == errStop {
+				break
+			}
+			return err
+		}
+	}
+	return a.w.Flush()
+}
+
+func (a *Aggregator) Close() error {
+	return a.w.Close()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var aggregateHeaders = []string{"region", "name", "price", "quantity"}
+
+var aggregateItems = [][]string{
+	{"east", "Apple", "60", "20"},
+	{"west", "Grapes", "140", "8"},
+	{"east", "Pineapple", "400", ""},
+	{"west", "Orange", "50", "14"},
+	{"east", "Apple", "70", "4"},
+}
+
+var aggregatorTests = []struct {
+	groupBy string
+	list    string
+	headers []string
+	dst     [][]string
+}{
+	{
+		groupBy: "region",
+		list:    "",
+		headers: []string{"region"},
+		dst: [][]string{
+			{"east"},
+			{"west"},
+		},
+	},
+	{
+		groupBy: "region",
+		list:    "count(),count(quantity),distinct(name)",
+		headers: []string{"region", "count()", "count(quantity)", "distinct(name)"},
+		dst: [][]string{
+			{"east", "3", "2", "2"},
+			{"west", "2", "2", "2"},
+		},
+	},
+	{
+		groupBy: "region",
+		list:    "sum(price),avg(quantity),min(price),max(price)",
+		headers: []string{"region", "sum(price)", "avg(quantity)", "min(price)", "max(price)"},
+		dst: [][]string{
+			{"east", "530", "12", "60", "400"},
+			{"west", "190", "11", "50", "140"},
+		},
+	},
+	{
+		groupBy: "name,region",
+		list:    "count()",
+		headers: []string{"name", "region", "count()"},
+		dst: [][]string{
+			{"Apple", "east", "2"},
+			{"Grapes", "west", "1"},
+			{"Pineapple", "east", "1"},
+			{"Orange", "west", "1"},
+		},
+	},
+	{
+		groupBy: "",
+		list:    "count(),sum(price)",
+		headers: []string{"count()", "sum(price)"},
+		dst: [][]string{
+			{"5", "720"},
+		},
+	},
+}
+
+func TestAggregator(t *testing.T) {
+	for _, test := range aggregatorTests {
+		w := &DummyWriter{}
+		a, err := NewAggregator(w, test.groupBy, test.list)
+		if err != nil {
+			t.Errorf("NewAggregator(%q, %q) returns %q, want nil",
+				test.groupBy, test.list, err)
+			continue
+		}
+		self := fmt.Sprintf("{groupBy=%q, list=%q}", test.groupBy, test.list)
+
+		if err = a.WriteHeaders(aggregateHeaders); err != nil {
+			t.Errorf("%s.WriteHeaders(%q) returns %q, want nil",
+				self, aggregateHeaders, err)
+			continue
+		}
+		for _, record := range aggregateItems {
+			if err = a.Write(record); err != nil {
+				t.Errorf("%s.Write(%q) returns %q, want nil",
+					self, record, err)
+			}
+		}
+		if err = a.Flush(); err != nil {
+			t.Errorf("%s.Flush() returns %q, want nil", self, err)
+		}
+
+		if !reflect.DeepEqual(w.headers, [][]string{test.headers}) {
+			t.Errorf("%s: headers = %q, want %q",
+				self, w.headers, [][]string{test.headers})
+		}
+		expect := test.dst
+		actual := w.records
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s",
+				self, toLines(actual), toLines(expect))
+		}
+	}
+}
+
+func TestAggregatorHeadersPerFile(t *testing.T) {
+	w := &DummyWriter{}
+	a, err := NewAggregator(w, "region", "sum(price)")
+	if err != nil {
+		t.Fatalf("NewAggregator returns %q, want nil", err)
+	}
+	files := []struct {
+		headers []string
+		records [][]string
+	}{
+		{
+			headers: []string{"region", "price"},
+			records: [][]string{{"east", "60"}, {"west", "140"}},
+		},
+		{
+			headers: []string{"price", "name", "region"},
+			records: [][]string{{"300", "Grape", "east"}},
+		},
+	}
+	for _, file := range files {
+		if err := a.WriteHeaders(file.headers); err != nil {
+			t.Fatalf("WriteHeaders(%q) returns %q, want nil",
+				file.headers, err)
+		}
+		for _, record := range file.records {
+			if err := a.Write(record); err != nil {
+				t.Fatalf("Write(%q) returns %q, want nil", record, err)
+			}
+		}
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatalf("Flush() returns %q, want nil", err)
+	}
+
+	expectHeaders := [][]string{{"region", "sum(price)"}}
+	if !reflect.DeepEqual(w.headers, expectHeaders) {
+		t.Errorf("headers = %q, want %q", w.headers, expectHeaders)
+	}
+	expect := [][]string{
+		{"east", "360"},
+		{"west", "140"},
+	}
+	if !reflect.DeepEqual(w.records, expect) {
+		t.Errorf("got:\n%s\nwant:\n%s", toLines(w.records), toLines(expect))
+	}
+}
+
+var aggregatorErrorTests = []struct {
+	groupBy string
+	list    string
+}{
+	{groupBy: "region", list: "median(price)"},
+	{groupBy: "region", list: "sum()"},
+	{groupBy: "region", list: "sum"},
+	{groupBy: "date", list: "count()"},
+	{groupBy: "region", list: "sum(date)"},
+	{groupBy: "region", list: "sum(name)"},
+}
+
+func TestAggregatorError(t *testing.T) {
+	for _, test := range aggregatorErrorTests {
+		a, err := NewAggregator(&DummyWriter{}, test.groupBy, test.list)
+		if err == nil {
+			err = a.WriteHeaders(aggregateHeaders)
+		}
+		for i := 0; err == nil && i < len(aggregateItems); i++ {
+			err = a.Write(aggregateItems[i])
+		}
+		if err == nil {
+			t.Errorf("aggregating %q by %q returns nil, want err",
+				test.list, test.groupBy)
+		}
+	}
+}
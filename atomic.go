@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// AtomicFile is a file which appears at the path only when committed.
+// It is written to a temporary file in the same directory,
+// and renamed to the path by Commit.
+type AtomicFile struct {
+	path      string
+	f         *os.File
+	committed bool
+}
+
+func CreateAtomicFile(path string) (*AtomicFile, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := createTempFile(dir, "."+base+".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{
+		path: path,
+		f:    f,
+	}, nil
+}
+
+// createTempFile creates a new file in dir whose name begins with prefix.
+// Unlike ioutil.TempFile, the mode of the file is 0666 masked by the umask
+// as os.Create, because the file becomes the output.
+func createTempFile(dir, prefix string) (*os.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 36))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &os.PathError{Op: "create", Path: filepath.Join(dir, prefix+"*"), Err: os.ErrExist}
+}
+
+func (a *AtomicFile) Write(p []byte) (n int, err error) {
+	return a.f.Write(p)
+}
+
+// Commit closes the temporary file and renames it to the path.
+// The file keeps the mode of the file which it replaces.
+func (a *AtomicFile) Commit() error {
+	if fi, err := os.Stat(a.path); err == nil {
+		if err := a.f.Chmod(fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err := a.f.Sync(); err != nil {
+		return err
+	}
+	if err := a.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(a.f.Name(), a.path); err != nil {
+		os.Remove(a.f.Name())
+		return err
+	}
+	a.committed = true
+	return nil
+}
+
+// Close removes the temporary file if it is not committed.
+func (a *AtomicFile) Close() error {
+	if a.committed {
+		return nil
+	}
+	a.f.Close()
+	return os.Remove(a.f.Name())
+}
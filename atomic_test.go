@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.csv")
+
+	a, err := CreateAtomicFile(path)
+	if err != nil {
+		t.Fatalf("CreateAtomicFile(%q) returns %q, want nil", path, err)
+	}
+	fmt.Fprintln(a, "a,b")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s exists before Commit()", path)
+	}
+	if err = a.Commit(); err != nil {
+		t.Fatalf("Commit() returns %q, want nil", err)
+	}
+	if err = a.Close(); err != nil {
+		t.Errorf("Close() after Commit() returns %q, want nil", err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "a,b\n" {
+		t.Errorf("%s = %q, want %q", path, b, "a,b\n")
+	}
+
+	a, err = CreateAtomicFile(path)
+	if err != nil {
+		t.Fatalf("CreateAtomicFile(%q) returns %q, want nil", path, err)
+	}
+	fmt.Fprintln(a, "partial")
+	if err = a.Close(); err != nil {
+		t.Errorf("Close() returns %q, want nil", err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "a,b\n" {
+		t.Errorf("%s = %q after Close() without Commit(), want %q",
+			path, b, "a,b\n")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("temporary file remains after Close()")
+	}
+}
+
+func TestAtomicFileMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "reference"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	fi, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultMode := fi.Mode().Perm()
+
+	path := filepath.Join(dir, "out.csv")
+	for _, expect := range []os.FileMode{defaultMode, 0600} {
+		a, err := CreateAtomicFile(path)
+		if err != nil {
+			t.Fatalf("CreateAtomicFile(%q) returns %q, want nil", path, err)
+		}
+		if err = a.Commit(); err != nil {
+			t.Fatalf("Commit() returns %q, want nil", err)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := fi.Mode().Perm(); mode != expect {
+			t.Errorf("mode of %s = %v, want %v", path, mode, expect)
+		}
+		if err = os.Chmod(path, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Chunker writes records into files which have at most the specified
+// number of records or bytes, named like "part-0001".
+type Chunker struct {
+	prefix          string
+	outputDelimiter string
+	maxRecords      int
+	maxBytes        int
+	headers         []string
+	n               int
+	records         int
+	bytes           int
+	f               *os.File
+	p               *Printer
+}
+
+// NewChunker returns a Chunker which starts a new file every maxRecords
+// records or before maxBytes bytes is exceeded.
+// Zero maxRecords or maxBytes means no limit.
+func NewChunker(prefix string, maxRecords int, maxBytes int, outputDelimiter string) *Chunker {
+	return &Chunker{
+		prefix:          prefix,
+		outputDelimiter: outputDelimiter,
+		maxRecords:      maxRecords,
+		maxBytes:        maxBytes,
+	}
+}
+
+func (c *Chunker) lineSize(record []string) int {
+	size := len(record)*len(c.outputDelimiter) + 1
+	if len(record) > 0 {
+		size -= len(c.outputDelimiter)
+	}
+	for _, field := range record {
+		size += len(field)
+	}
+	return size
+}
+
+func (c *Chunker) WriteHeaders(headers []string) error {
+	if c.headers == nil {
+		c.headers = headers
+	}
+	return nil
+}
+
+func (c *Chunker) full(record []string) bool {
+	switch {
+	case c.f == nil:
+		return true
+	case c.records == 0:
+		return false
+	case c.maxRecords > 0 && c.records >= c.maxRecords:
+		return true
+	case c.maxBytes > 0 && c.bytes+c.lineSize(record) > c.maxBytes:
+		return true
+	}
+	return false
+}
+
+// name returns the name of the n-th file.
+func (c *Chunker) name(n int) string {
+	return fmt.Sprintf("%s%04d", c.prefix, n)
+}
+
+func (c *Chunker) next() error {
+	if err := c.closeFile(); err != nil {
+		return err
+	}
+
+	c.n++
+	f, err := os.Create(c.name(c.n))
+	if err != nil {
+		return err
+	}
+	c.f = f
+	c.p = NewPrinter(f, c.outputDelimiter, true)
+	c.records = 0
+	c.bytes = c.lineSize(c.headers)
+	return c.p.WriteHeaders(c.headers)
+}
+
+func (c *Chunker) Write(record []string) error {
+	if c.full(record) {
+		if err := c.next(); err != nil {
+			return err
+		}
+	}
+	c.records++
+	c.bytes += c.lineSize(record)
+	return c.p.Write(record)
+}
+
+func (c *Chunker) closeFile() error {
+	if c.f == nil {
+		return nil
+	}
+	f, p := c.f, c.p
+	c.f, c.p = nil, nil
+	err := p.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *Chunker) Flush() error {
+	return c.closeFile()
+}
+
+// Abort closes the current file, and removes all the files written by c.
+func (c *Chunker) Abort() error {
+	if c.f != nil {
+		c.f.Close()
+		c.f, c.p = nil, nil
+	}
+	var err error
+	for ; c.n > 0; c.n-- {
+		if rerr := os.Remove(c.name(c.n)); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+func (c *Chunker) Close() error {
+	return c.closeFile()
+}
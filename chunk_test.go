@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var chunkerTests = []struct {
+	maxRecords int
+	maxBytes   int
+	files      map[string]string
+}{
+	{
+		maxRecords: 2,
+		files: map[string]string{
+			"part-0001": "name,price\nApple,60\nGrapes,140\n",
+			"part-0002": "name,price\nPineapple,400\nOrange,50\n",
+			"part-0003": "name,price\nBanana,9\n",
+		},
+	},
+	{
+		maxBytes: 32,
+		files: map[string]string{
+			"part-0001": "name,price\nApple,60\nGrapes,140\n",
+			"part-0002": "name,price\nPineapple,400\n",
+			"part-0003": "name,price\nOrange,50\nBanana,9\n",
+		},
+	},
+	{
+		maxBytes: 1,
+		files: map[string]string{
+			"part-0001": "name,price\nApple,60\n",
+			"part-0002": "name,price\nGrapes,140\n",
+			"part-0003": "name,price\nPineapple,400\n",
+			"part-0004": "name,price\nOrange,50\n",
+			"part-0005": "name,price\nBanana,9\n",
+		},
+	},
+}
+
+func TestChunker(t *testing.T) {
+	headers := []string{"name", "price"}
+	src := [][]string{
+		{"Apple", "60"},
+		{"Grapes", "140"},
+		{"Pineapple", "400"},
+		{"Orange", "50"},
+		{"Banana", "9"},
+	}
+	for _, test := range chunkerTests {
+		dir, err := ioutil.TempDir("", "csvp-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		self := fmt.Sprintf("{maxRecords=%d, maxBytes=%d}",
+			test.maxRecords, test.maxBytes)
+
+		c := NewChunker(filepath.Join(dir, "part-"), test.maxRecords, test.maxBytes, ",")
+		if err = c.WriteHeaders(headers); err != nil {
+			t.Errorf("%s.WriteHeaders(%q) returns %q, want nil",
+				self, headers, err)
+			continue
+		}
+		for _, record := range src {
+			if err = c.Write(record); err != nil {
+				t.Errorf("%s.Write(%q) returns %q, want nil",
+					self, record, err)
+			}
+		}
+		if err = c.Flush(); err != nil {
+			t.Errorf("%s.Flush() returns %q, want nil", self, err)
+		}
+
+		actual := readTree(t, dir)
+		expect := test.files
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("%s:\ngot: %q\nwant: %q", self, actual, expect)
+		}
+	}
+}
+
+func TestChunkerAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewChunker(filepath.Join(dir, "part-"), 1, 0, ",")
+	c.WriteHeaders([]string{"name"})
+	for _, record := range [][]string{{"Apple"}, {"Grapes"}, {"Banana"}} {
+		if err = c.Write(record); err != nil {
+			t.Errorf("Write(%q) returns %q, want nil", record, err)
+		}
+	}
+	if err = c.Abort(); err != nil {
+		t.Errorf("Abort() returns %q, want nil", err)
+	}
+	if err = c.Close(); err != nil {
+		t.Errorf("Close() after Abort() returns %q, want nil", err)
+	}
+	if files := readTree(t, dir); len(files) != 0 {
+		t.Errorf("files remain after Abort(): %q", files)
+	}
+}
@@ -0,0 +1,278 @@
+package csvp
+
+import (
+	"fmt"
+)
+
+// The combinators build a Selector from other selectors.
+//
+// A combined selector drops the header row if any of its selectors drops it,
+// so a selection which uses header names never prints the header row,
+// as Headers does.
+//
+// When the combined selectors are All, Indexes, Headers or combinations
+// of them, the combined selector is resolved to the indexes of the input
+// columns by ParseHeaders, and only the selected columns are extracted
+// from the records.
+
+// sourceColumns returns the index of the input column of each column
+// selected by s from n columns, or false if s is not resolved to columns.
+// An index is -1 or out of range for an empty column.
+func sourceColumns(s Selector, n int) ([]int, bool) {
+	switch s := s.(type) {
+	case *All:
+		columns := make([]int, n)
+		for i := range columns {
+			columns[i] = i
+		}
+		return columns, true
+	case columnSelector:
+		columns := s.columns()
+		return columns, columns != nil
+	}
+	return nil, false
+}
+
+type concat struct {
+	a, b    Selector
+	indexes []int
+	headers []string
+}
+
+// Concat returns a Selector which selects the columns of a,
+// and then the columns of b.
+func Concat(a, b Selector) Selector {
+	return &concat{a: a, b: b}
+}
+
+func (c *concat) DropHeaders() bool {
+	return c.a.DropHeaders() || c.b.DropHeaders()
+}
+
+func (c *concat) ParseHeaders(headers []string) error {
+	if err := c.a.ParseHeaders(headers); err != nil {
+		return err
+	}
+	if err := c.b.ParseHeaders(headers); err != nil {
+		return err
+	}
+	ha, err := outputHeaders(c.a, headers)
+	if err != nil {
+		return err
+	}
+	hb, err := outputHeaders(c.b, headers)
+	if err != nil {
+		return err
+	}
+	c.headers = append(append(make([]string, 0, len(ha)+len(hb)), ha...), hb...)
+
+	c.indexes = nil
+	ca, ok := sourceColumns(c.a, len(headers))
+	if !ok {
+		return nil
+	}
+	cb, ok := sourceColumns(c.b, len(headers))
+	if !ok {
+		return nil
+	}
+	c.indexes = append(append(make([]int, 0, len(ca)+len(cb)), ca...), cb...)
+	return nil
+}
+
+func (c *concat) columns() []int {
+	return c.indexes
+}
+
+func (c *concat) OutputHeaders() []string {
+	return c.headers
+}
+
+func (c *concat) Select(record []string) ([]string, error) {
+	if c.indexes != nil {
+		return pick(record, c.indexes), nil
+	}
+	ra, err := c.a.Select(record)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := c.b.Select(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(make([]string, 0, len(ra)+len(rb)), ra...), rb...), nil
+}
+
+type chain struct {
+	a, b    Selector
+	indexes []int
+	headers []string
+}
+
+// Chain returns a Selector which selects columns by b
+// from the columns selected by a.
+// The headers of b are the output headers of a.
+func Chain(a, b Selector) Selector {
+	return &chain{a: a, b: b}
+}
+
+func (c *chain) DropHeaders() bool {
+	return c.a.DropHeaders() || c.b.DropHeaders()
+}
+
+func (c *chain) ParseHeaders(headers []string) error {
+	if err := c.a.ParseHeaders(headers); err != nil {
+		return err
+	}
+	selected, err := outputHeaders(c.a, headers)
+	if err != nil {
+		return err
+	}
+	if err := c.b.ParseHeaders(selected); err != nil {
+		return err
+	}
+	c.headers, err = outputHeaders(c.b, selected)
+	if err != nil {
+		return err
+	}
+
+	c.indexes = nil
+	ca, ok := sourceColumns(c.a, len(headers))
+	if !ok {
+		return nil
+	}
+	cb, ok := sourceColumns(c.b, len(selected))
+	if !ok {
+		return nil
+	}
+	c.indexes = make([]int, len(cb))
+	for i, column := range cb {
+		c.indexes[i] = -1
+		if column >= 0 && column < len(ca) {
+			c.indexes[i] = ca[column]
+		}
+	}
+	return nil
+}
+
+func (c *chain) columns() []int {
+	return c.indexes
+}
+
+func (c *chain) OutputHeaders() []string {
+	return c.headers
+}
+
+func (c *chain) Select(record []string) ([]string, error) {
+	if c.indexes != nil {
+		return pick(record, c.indexes), nil
+	}
+	selected, err := c.a.Select(record)
+	if err != nil {
+		return nil, err
+	}
+	return c.b.Select(selected)
+}
+
+type except struct {
+	s       Selector
+	indexes []int
+	headers []string
+}
+
+// Except returns a Selector which selects the columns not selected by s,
+// in the order of the input.
+// s must be All, Indexes, Headers or a combination of them.
+func Except(s Selector) Selector {
+	return &except{s: s}
+}
+
+func (e *except) DropHeaders() bool {
+	return e.s.DropHeaders()
+}
+
+func (e *except) ParseHeaders(headers []string) error {
+	if err := e.s.ParseHeaders(headers); err != nil {
+		return err
+	}
+	excluded, ok := sourceColumns(e.s, len(headers))
+	if !ok {
+		return fmt.Errorf("%T: cannot select the other columns", e.s)
+	}
+
+	drop := make([]bool, len(headers))
+	for _, column := range excluded {
+		if column >= 0 && column < len(drop) {
+			drop[column] = true
+		}
+	}
+	e.indexes = make([]int, 0, len(headers))
+	for i := range headers {
+		if !drop[i] {
+			e.indexes = append(e.indexes, i)
+		}
+	}
+	e.headers = pick(headers, e.indexes)
+	return nil
+}
+
+func (e *except) columns() []int {
+	return e.indexes
+}
+
+func (e *except) OutputHeaders() []string {
+	return e.headers
+}
+
+func (e *except) Select(record []string) ([]string, error) {
+	return pick(record, e.indexes), nil
+}
+
+type rename struct {
+	s       Selector
+	names   []string
+	headers []string
+}
+
+// Rename returns a Selector which selects the columns of s,
+// and renames the output headers to names in order.
+// The output headers after names are not renamed.
+// It is an error that names are more than the selected columns.
+func Rename(s Selector, names []string) Selector {
+	return &rename{s: s, names: names}
+}
+
+func (r *rename) DropHeaders() bool {
+	return r.s.DropHeaders()
+}
+
+func (r *rename) ParseHeaders(headers []string) error {
+	if err := r.s.ParseHeaders(headers); err != nil {
+		return err
+	}
+	selected, err := outputHeaders(r.s, headers)
+	if err != nil {
+		return err
+	}
+	if len(r.names) > len(selected) {
+		return fmt.Errorf("%d names for %d columns", len(r.names), len(selected))
+	}
+	r.headers = make([]string, len(selected))
+	copy(r.headers, selected)
+	copy(r.headers, r.names)
+	return nil
+}
+
+func (r *rename) columns() []int {
+	if s, ok := r.s.(columnSelector); ok {
+		return s.columns()
+	}
+	return nil
+}
+
+func (r *rename) OutputHeaders() []string {
+	return r.headers
+}
+
+func (r *rename) Select(record []string) ([]string, error) {
+	return r.s.Select(record)
+}
@@ -0,0 +1,180 @@
+package csvp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var combinatorSource = `
+a,b,c,d
+1,2,3,4
+5,6,7,8
+`[1:]
+
+var combinatorTests = []struct {
+	name     string
+	selector Selector
+	headers  []string
+	records  []string
+}{
+	{
+		name:     "Concat(Indexes, Indexes)",
+		selector: Concat(NewIndexes("3"), NewIndexes("1-2")),
+		headers:  []string{"c", "a", "b"},
+		records:  []string{"c\ta\tb", "3\t1\t2", "7\t5\t6"},
+	},
+	{
+		name:     "Concat(Indexes, Headers)",
+		selector: Concat(NewIndexes("4"), NewHeaders("b,x")),
+		headers:  []string{"d", "b", ""},
+		records:  []string{"4\t2\t", "8\t6\t"},
+	},
+	{
+		name:     "Concat(All, Indexes)",
+		selector: Concat(NewAll(), NewIndexes("1")),
+		headers:  []string{"a", "b", "c", "d", "a"},
+		records:  []string{"a\tb\tc\td\ta", "1\t2\t3\t4\t1", "5\t6\t7\t8\t5"},
+	},
+	{
+		name:     "Concat(DummyAll, Indexes)",
+		selector: Concat(&DummyAll{}, NewIndexes("2")),
+		headers:  []string{"a", "b", "c", "d", "b"},
+		records:  []string{"a\tb\tc\td\tb", "1\t2\t3\t4\t2", "5\t6\t7\t8\t6"},
+	},
+	{
+		name:     "Chain(Indexes, Headers)",
+		selector: Chain(NewIndexes("2-4"), NewHeaders("d,b")),
+		headers:  []string{"d", "b"},
+		records:  []string{"4\t2", "8\t6"},
+	},
+	{
+		name:     "Chain(Headers, Indexes)",
+		selector: Chain(NewHeaders("d,c,b"), NewIndexes("1,3,5")),
+		headers:  []string{"d", "b", ""},
+		records:  []string{"4\t2\t", "8\t6\t"},
+	},
+	{
+		name:     "Chain(DummyAll, Indexes)",
+		selector: Chain(&DummyAll{}, NewIndexes("4,1")),
+		headers:  []string{"d", "a"},
+		records:  []string{"d\ta", "4\t1", "8\t5"},
+	},
+	{
+		name:     "Except(Indexes)",
+		selector: Except(NewIndexes("2,9")),
+		headers:  []string{"a", "c", "d"},
+		records:  []string{"a\tc\td", "1\t3\t4", "5\t7\t8"},
+	},
+	{
+		name:     "Except(Headers)",
+		selector: Except(NewHeaders("d,a,x")),
+		headers:  []string{"b", "c"},
+		records:  []string{"2\t3", "6\t7"},
+	},
+	{
+		name:     "Except(All)",
+		selector: Except(NewAll()),
+		headers:  []string{},
+		records:  []string{"", "", ""},
+	},
+	{
+		name:     "Except(Concat(Indexes, Headers))",
+		selector: Except(Concat(NewIndexes("1"), NewHeaders("c"))),
+		headers:  []string{"b", "d"},
+		records:  []string{"2\t4", "6\t8"},
+	},
+	{
+		name:     "Rename(Indexes)",
+		selector: Rename(NewIndexes("3,1"), []string{"x"}),
+		headers:  []string{"x", "a"},
+		records:  []string{"x\ta", "3\t1", "7\t5"},
+	},
+	{
+		name:     "Rename(Headers)",
+		selector: Rename(NewHeaders("b,a"), []string{"x", "y"}),
+		headers:  []string{"x", "y"},
+		records:  []string{"2\t1", "6\t5"},
+	},
+	{
+		name:     "Chain(Rename(Indexes), Headers)",
+		selector: Chain(Rename(NewIndexes("1-2"), []string{"x", "y"}), NewHeaders("y")),
+		headers:  []string{"y"},
+		records:  []string{"2", "6"},
+	},
+	{
+		name:     "Concat(Rename(Headers), Indexes)",
+		selector: Concat(Rename(NewHeaders("a"), []string{"x"}), NewIndexes("2")),
+		headers:  []string{"x", "b"},
+		records:  []string{"1\t2", "5\t6"},
+	},
+}
+
+func TestCombinators(t *testing.T) {
+	for _, test := range combinatorTests {
+		for _, jobs := range []int{1, 4} {
+			c := NewCSVScanner(test.selector, strings.NewReader(combinatorSource))
+			c.SetJobs(jobs)
+			records := []string{}
+			for c.Scan() {
+				records = append(records, c.Text())
+			}
+			if err := c.Err(); err != nil {
+				t.Errorf("%s: jobs=%d: got error %q", test.name, jobs, err)
+				continue
+			}
+			if !reflect.DeepEqual(c.Headers(), test.headers) {
+				t.Errorf("%s: jobs=%d: headers:\ngot: %q\nwant: %q",
+					test.name, jobs, c.Headers(), test.headers)
+			}
+			if !reflect.DeepEqual(records, test.records) {
+				t.Errorf("%s: jobs=%d: records:\ngot: %q\nwant: %q",
+					test.name, jobs, records, test.records)
+			}
+		}
+	}
+}
+
+func TestCombinatorsDropHeaders(t *testing.T) {
+	tests := []struct {
+		selector    Selector
+		dropHeaders bool
+	}{
+		{Concat(NewIndexes("1"), NewIndexes("2")), false},
+		{Concat(NewIndexes("1"), NewHeaders("b")), true},
+		{Concat(NewHeaders("a"), NewAll()), true},
+		{Chain(NewIndexes("1-2"), NewIndexes("1")), false},
+		{Chain(NewIndexes("1-2"), NewHeaders("b")), true},
+		{Chain(NewHeaders("a,b"), NewIndexes("1")), true},
+		{Except(NewIndexes("1")), false},
+		{Except(NewHeaders("a")), true},
+		{Rename(NewIndexes("1"), []string{"x"}), false},
+		{Rename(NewHeaders("a"), []string{"x"}), true},
+	}
+	for i, test := range tests {
+		if got := test.selector.DropHeaders(); got != test.dropHeaders {
+			t.Errorf("tests[%d]: DropHeaders() = %v, want %v",
+				i, got, test.dropHeaders)
+		}
+	}
+}
+
+func TestCombinatorsError(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector Selector
+	}{
+		{"Except(DummyAll)", Except(&DummyAll{})},
+		{"Rename with too many names", Rename(NewIndexes("1"), []string{"x", "y"})},
+		{"Concat with a parse error", Concat(NewIndexes("1"), &DummyAll{causeErrorAtParseHeaders: true})},
+		{"Chain with an invalid list", Chain(NewIndexes("1-2"), NewIndexes("a"))},
+	}
+	for _, test := range tests {
+		c := NewCSVScanner(test.selector, strings.NewReader(combinatorSource))
+		for c.Scan() {
+		}
+		if c.Err() == nil {
+			t.Errorf("%s: got no error, want error", test.name)
+		}
+	}
+}
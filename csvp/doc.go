@@ -0,0 +1,26 @@
+// Package csvp selects columns of CSV records by indexes or headers.
+//
+// A CSVScanner reads records from an io.Reader, and selects columns of
+// them by a Selector. The selectors are All, Indexes and Headers:
+//
+//	csvp.NewAll()                 // all columns
+//	csvp.NewIndexes("1,3-5,7-")   // columns by indexes numbered from 1
+//	csvp.NewHeaders("name,price") // columns by header names
+//
+// Indexes and Headers select columns after parsing the header row,
+// so only the columns to select are extracted from records
+// which have no quotes.
+//
+// Selectors are combined by Concat, Chain, Except and Rename:
+//
+//	csvp.Concat(csvp.NewIndexes("1"), csvp.NewHeaders("price")) // both
+//	csvp.Chain(csvp.NewIndexes("2-"), csvp.NewIndexes("1"))     // the 2nd column
+//	csvp.Except(csvp.NewHeaders("id"))                          // all but id
+//	csvp.Rename(csvp.NewIndexes("1"), []string{"name"})         // rename headers
+//
+// NewSelector makes a selector from a spec like "indexes:1,3-5",
+// and RegisterSelector adds a selection language to it.
+//
+// ParseRanges, ParseIndex, ParseHeaderList and Unescape parse lists
+// in the same syntax as Indexes and Headers.
+package csvp
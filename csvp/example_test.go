@@ -0,0 +1,116 @@
+package csvp_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+func ExampleCSVScanner() {
+	r := strings.NewReader(`name,price,quantity
+Apple,60,20
+Grapes,140,8
+`)
+	c := csvp.NewCSVScanner(csvp.NewHeaders("price,name"), r)
+	for c.Scan() {
+		fmt.Println(c.Record())
+	}
+	if err := c.Err(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// [60 Apple]
+	// [140 Grapes]
+}
+
+func ExampleNewIndexes() {
+	r := strings.NewReader(`name,price,quantity
+Apple,60,20
+`)
+	c := csvp.NewCSVScanner(csvp.NewIndexes("3,1"), r)
+	c.SetOutputDelimiter(",")
+	for c.Scan() {
+		fmt.Println(c.Text())
+	}
+	// Output:
+	// quantity,name
+	// 20,Apple
+}
+
+func ExampleHeaders_Missing() {
+	h := csvp.NewHeaders("name,color")
+	if err := h.ParseHeaders([]string{"name", "price"}); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(h.Missing())
+	// Output:
+	// [color]
+}
+
+func ExampleParseRanges() {
+	ranges, err := csvp.ParseRanges("1,3-5,7-")
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(ranges)
+	// Output:
+	// [{1 1} {3 5} {7 0}]
+}
+
+func ExampleCSVScanner_Line() {
+	r := strings.NewReader(`name,note
+Apple,"red
+or green"
+Grapes,purple
+`)
+	c := csvp.NewCSVScanner(csvp.NewHeaders("note"), r)
+	fmt.Println(c.Headers())
+	for c.Scan() {
+		fmt.Printf("%d: %q\n", c.Line(), c.Record())
+	}
+	fmt.Println(c.Headers())
+	// Output:
+	// []
+	// 2: ["red\nor green"]
+	// 4: ["purple"]
+	// [note]
+}
+
+func ExampleExcept() {
+	r := strings.NewReader(`id,name,price
+1,Apple,60
+2,Grapes,140
+`)
+	s := csvp.Rename(csvp.Except(csvp.NewHeaders("id")), []string{"item"})
+	c := csvp.NewCSVScanner(s, r)
+	for c.Scan() {
+		fmt.Println(c.Record())
+	}
+	fmt.Println(c.Headers())
+	// Output:
+	// [Apple 60]
+	// [Grapes 140]
+	// [item price]
+}
+
+func ExampleRegisterSelector() {
+	csvp.RegisterSelector("example-upper", func(expr string) (csvp.Selector, error) {
+		return csvp.NewHeaders(strings.ToUpper(expr)), nil
+	})
+
+	r := strings.NewReader(`NAME,PRICE
+Apple,60
+`)
+	s, err := csvp.NewSelector("example-upper:price")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	c := csvp.NewCSVScanner(s, r)
+	for c.Scan() {
+		fmt.Println(c.Record())
+	}
+	// Output:
+	// [60]
+}
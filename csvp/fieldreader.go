@@ -0,0 +1,236 @@
+package csvp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"unicode/utf8"
+)
+
+// columnSelector is implemented by selectors which use only some columns.
+// columns returns the indexes of the used columns after ParseHeaders,
+// or nil if the columns are not known.
+type columnSelector interface {
+	columns() []int
+}
+
+func (i *Indexes) columns() []int {
+	return i.indexes
+}
+
+func (h *Headers) columns() []int {
+	return h.indexes
+}
+
+// validDelim reports whether r is a delimiter accepted by csv.Reader.
+func validDelim(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' &&
+		utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// lineFeeder feeds csv.Reader with lines from br.
+// It never returns bytes beyond the end of a line from a single Read,
+// so csv.Reader does not read ahead of the record it parses.
+type lineFeeder struct {
+	br      *bufio.Reader
+	pending []byte
+	lines   int
+	err     error
+}
+
+func (l *lineFeeder) Read(p []byte) (n int, err error) {
+	if len(l.pending) == 0 {
+		if l.err != nil {
+			return 0, l.err
+		}
+		line, err := l.br.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			l.err = err
+		}
+		if len(line) == 0 {
+			return 0, l.err
+		}
+		l.pending = append(l.pending[:0], line...)
+	}
+	n = copy(p, l.pending)
+	l.lines += bytes.Count(l.pending[:n], []byte{'\n'})
+	l.pending = l.pending[n:]
+	return n, nil
+}
+
+// fieldReader reads records like csv.Reader with the default options.
+// It splits lines without quotes by itself and extracts only the columns
+// set by setColumns, and parses lines with quotes by csv.Reader.
+type fieldReader struct {
+	comma           rune
+	sep             []byte
+	br              *bufio.Reader
+	raw             []byte
+	fastLines       int
+	line            int
+	fieldsPerRecord int
+	need            []bool
+	bounds          []int
+	record          []string
+	feeder          *lineFeeder
+	slow            *csv.Reader
+}
+
+func newFieldReader(r io.Reader) *fieldReader {
+	br := bufio.NewReader(r)
+	f := &fieldReader{
+		br:     br,
+		feeder: &lineFeeder{br: br},
+	}
+	f.setComma(',')
+	return f
+}
+
+func (f *fieldReader) setComma(ch rune) {
+	f.comma = ch
+	f.sep = make([]byte, 0, utf8.UTFMax)
+	if validDelim(ch) {
+		f.sep = f.sep[:utf8.EncodeRune(f.sep[:utf8.UTFMax], ch)]
+	}
+	if f.slow != nil {
+		f.slow.Comma = ch
+	}
+}
+
+// setColumns makes Read extract only the fields of columns.
+// The other fields of the records may be left empty.
+func (f *fieldReader) setColumns(columns []int) {
+	last := -1
+	for _, column := range columns {
+		if column > last {
+			last = column
+		}
+	}
+	f.need = make([]bool, last+1)
+	for _, column := range columns {
+		if column >= 0 {
+			f.need[column] = true
+		}
+	}
+	f.record = nil
+}
+
+func (f *fieldReader) readLine() ([]byte, error) {
+	line, err := f.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		f.raw = append(f.raw[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = f.br.ReadSlice('\n')
+			f.raw = append(f.raw, line...)
+		}
+		line = f.raw
+	}
+	return line, err
+}
+
+// lines returns the number of lines read.
+func (f *fieldReader) lines() int {
+	return f.fastLines + f.feeder.lines
+}
+
+// Read reads a record.
+// The returned record may be reused by the next Read after setColumns.
+func (f *fieldReader) Read() (record []string, err error) {
+	if !validDelim(f.comma) {
+		return f.readSlow(nil)
+	}
+	for {
+		line, err := f.readLine()
+		if len(line) == 0 || (err != nil && err != io.EOF) {
+			return nil, err
+		}
+		if bytes.IndexByte(line, '"') != -1 {
+			return f.readSlow(line)
+		}
+		f.fastLines++
+
+		// Trim the end of line in the same way as csv.Reader.
+		n := len(line)
+		switch {
+		case line[n-1] == '\n':
+			n--
+			if n > 0 && line[n-1] == '\r' {
+				n--
+			}
+		case line[n-1] == '\r' && err == io.EOF:
+			n--
+		}
+		if n == 0 {
+			continue
+		}
+		f.line = f.lines()
+		return f.split(line[:n])
+	}
+}
+
+func (f *fieldReader) split(line []byte) ([]string, error) {
+	count := bytes.Count(line, f.sep) + 1
+	if f.fieldsPerRecord == 0 {
+		f.fieldsPerRecord = count
+	} else if count != f.fieldsPerRecord {
+		n := f.lines()
+		return nil, &csv.ParseError{StartLine: n, Line: n, Column: 1, Err: csv.ErrFieldCount}
+	}
+
+	if f.need == nil {
+		s := string(line)
+		record := make([]string, count)
+		for i := 0; i < count-1; i++ {
+			j := bytes.Index(line, f.sep)
+			record[i], s = s[:j], s[j+len(f.sep):]
+			line = line[j+len(f.sep):]
+		}
+		record[count-1] = s
+		return record, nil
+	}
+
+	if len(f.record) != count {
+		f.record = make([]string, count)
+	}
+	f.bounds = f.bounds[:0]
+	for i, pos := 0, 0; i < len(f.need) && i < count; i++ {
+		end := len(line)
+		if j := bytes.Index(line[pos:], f.sep); j != -1 {
+			end = pos + j
+		}
+		f.bounds = append(f.bounds, pos, end)
+		pos = end + len(f.sep)
+	}
+	if len(f.bounds) == 0 {
+		return f.record, nil
+	}
+	s := string(line[:f.bounds[len(f.bounds)-1]])
+	for i := 0; i < len(f.bounds)/2; i++ {
+		if f.need[i] {
+			f.record[i] = s[f.bounds[2*i]:f.bounds[2*i+1]]
+		}
+	}
+	return f.record, nil
+}
+
+// readSlow reads a record starting with line by csv.Reader.
+func (f *fieldReader) readSlow(line []byte) ([]string, error) {
+	if f.slow == nil {
+		f.slow = csv.NewReader(f.feeder)
+		f.slow.Comma = f.comma
+	}
+	f.feeder.pending = append(f.feeder.pending[:0], line...)
+	f.slow.FieldsPerRecord = f.fieldsPerRecord
+	record, err := f.slow.Read()
+	f.fieldsPerRecord = f.slow.FieldsPerRecord
+	if len(record) > 0 {
+		line, _ := f.slow.FieldPos(0)
+		f.line = line + f.fastLines
+	}
+	if e, ok := err.(*csv.ParseError); ok {
+		e.StartLine += f.fastLines
+		e.Line += f.fastLines
+	}
+	return record, err
+}
@@ -0,0 +1,130 @@
+package csvp
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var fieldReaderSeeds = []string{
+	"",
+	"a,b,c\n1,2,3\n",
+	"a,b,c\r\n1,2,3\r\n",
+	"a,b,c\n1,2,3",
+	"a,b,c\n1,2,3\r",
+	"a,b,c\n\n\r\n1,2,3\n\n",
+	"a,b,c\n1,2\n",
+	"a,b,c\n1,2,3,4\n",
+	"a,b\n\"1,2\",3\n4,5\n",
+	"a,b\n\"1\n2\",3\n4,5\n",
+	"a,b\n1,\"2\"\"3\"\n4,5\n6,7,8\n",
+	"a,b\n1,2\"3\n",
+	"a,b\n1,\"2\n",
+	"a,b\n\"1\"x,2\n",
+	"a\rb,c\n1\r,2\n",
+	"a,b\n1,2\r\r\n3,4\r\r",
+	",\n,\n",
+}
+
+// readAll reads records until an error, and returns them with the error.
+func readAll(read func() ([]string, error)) ([][]string, string) {
+	var records [][]string
+	for {
+		record, err := read()
+		if err == io.EOF {
+			return records, ""
+		}
+		if err != nil {
+			return records, err.Error()
+		}
+		records = append(records, append([]string{}, record...))
+	}
+}
+
+// project clears the fields not in columns except in the first record.
+func project(records [][]string, columns []int) {
+	for i := 1; i < len(records); i++ {
+		a := make([]string, len(records[i]))
+		for _, column := range columns {
+			if column < len(a) {
+				a[column] = records[i][column]
+			}
+		}
+		records[i] = a
+	}
+}
+
+func checkFieldReader(t *testing.T, src string, comma rune, columns []int) {
+	expect := csv.NewReader(strings.NewReader(src))
+	expect.Comma = comma
+	actual := newFieldReader(strings.NewReader(src))
+	actual.setComma(comma)
+
+	expectRecords, expectErr := readAll(expect.Read)
+	actualRecords, actualErr := readAll(func() ([]string, error) {
+		record, err := actual.Read()
+		if err == nil && columns != nil {
+			actual.setColumns(columns)
+		}
+		return record, err
+	})
+
+	if columns != nil {
+		project(expectRecords, columns)
+		project(actualRecords, columns)
+	}
+	if !reflect.DeepEqual(actualRecords, expectRecords) || actualErr != expectErr {
+		t.Errorf("src=%q comma=%q columns=%v:\ngot:\n%q %q\nwant:\n%q %q",
+			src, comma, columns,
+			actualRecords, actualErr, expectRecords, expectErr)
+	}
+}
+
+func TestFieldReader(t *testing.T) {
+	for _, src := range fieldReaderSeeds {
+		checkFieldReader(t, src, ',', nil)
+		checkFieldReader(t, src, ',', []int{1})
+		checkFieldReader(t, src, ',', []int{2, 0})
+		checkFieldReader(t, src, ',', []int{})
+	}
+}
+
+func TestFieldReaderWithDelimiter(t *testing.T) {
+	for _, comma := range []rune{'\t', ';', 'あ', '"', '\n'} {
+		for _, src := range fieldReaderSeeds {
+			src = strings.Replace(src, ",", string(comma), -1)
+			checkFieldReader(t, src, comma, nil)
+			checkFieldReader(t, src, comma, []int{1})
+		}
+	}
+}
+
+func TestFieldReaderLongLine(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	src := fmt.Sprintf("a,b\n%s,1\n\"%s\n\",2\n3,%s", long, long, long)
+	checkFieldReader(t, src, ',', nil)
+	checkFieldReader(t, src, ',', []int{1})
+}
+
+func FuzzFieldReader(f *testing.F) {
+	for _, src := range fieldReaderSeeds {
+		f.Add(src, uint8(1))
+	}
+	f.Fuzz(func(t *testing.T, src string, column uint8) {
+		checkFieldReader(t, src, ',', nil)
+		checkFieldReader(t, src, ',', []int{int(column % 4)})
+	})
+}
+
+func FuzzFieldReaderWithDelimiter(f *testing.F) {
+	for _, src := range fieldReaderSeeds {
+		f.Add(src, '\t')
+	}
+	f.Fuzz(func(t *testing.T, src string, comma rune) {
+		checkFieldReader(t, src, comma, nil)
+		checkFieldReader(t, src, comma, []int{0, 2})
+	})
+}
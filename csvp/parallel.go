@@ -0,0 +1,405 @@
+package csvp
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"sync"
+)
+
+var parallelBlockSize = 1 << 20
+
+// block is a part of the input which starts at a record boundary.
+type block struct {
+	r      io.Reader
+	lines  int
+	err    error
+	result chan *batch
+}
+
+// batch is the selected records of a block and their lines.
+type batch struct {
+	records [][]string
+	lines   []int
+	err     error
+}
+
+// pipeline parses blocks of the input concurrently,
+// and returns the records in the original order.
+type pipeline struct {
+	order chan *block
+	work  chan *block
+	done  chan struct{}
+	wg    sync.WaitGroup
+	batch *batch
+	i     int
+}
+
+// seekableFile is an input which can be split into byte ranges.
+type seekableFile interface {
+	io.ReaderAt
+	io.Seeker
+	Stat() (os.FileInfo, error)
+}
+
+// rangeScan is the result of scanning a range of the input for quotes.
+// first and lines are indexed by the parity of quotes before the range.
+type rangeScan struct {
+	quotes   int
+	newlines int
+	first    [2]int
+	lines    [2]int
+}
+
+// scanRange returns the end of the first line outside quotes in b,
+// for each parity of quotes before b.
+func scanRange(b []byte) rangeScan {
+	s := rangeScan{
+		quotes:   bytes.Count(b, []byte{'"'}),
+		newlines: bytes.Count(b, []byte{'\n'}),
+		first:    [2]int{-1, -1},
+	}
+	q := 0
+	for i := 0; i < len(b) && (s.first[0] == -1 || s.first[1] == -1); q ^= 1 {
+		seg := b[i:]
+		j := bytes.IndexByte(seg, '"')
+		if j != -1 {
+			seg = seg[:j]
+		}
+		// A newline in seg is outside quotes if the parity before b is q.
+		if s.first[q] == -1 {
+			if k := bytes.IndexByte(seg, '\n'); k != -1 {
+				s.first[q] = i + k + 1
+				s.lines[q] = bytes.Count(b[:i+k+1], []byte{'\n'})
+			}
+		}
+		if j == -1 {
+			break
+		}
+		i += j + 1
+	}
+	return s
+}
+
+// lastRecordEnd returns the end of the last line outside quotes in b,
+// or -1 if there is no such line, when b starts inside quotes if quoted.
+// It also returns whether the end of b is inside quotes,
+// so that a growing input is scanned incrementally.
+func lastRecordEnd(b []byte, quoted bool) (int, bool) {
+	end := -1
+	for i := 0; i < len(b); quoted = !quoted {
+		j := bytes.IndexByte(b[i:], '"')
+		if !quoted {
+			seg := b[i:]
+			if j != -1 {
+				seg = seg[:j]
+			}
+			if k := bytes.LastIndexByte(seg, '\n'); k != -1 {
+				end = i + k + 1
+			}
+		}
+		if j == -1 {
+			break
+		}
+		i += j + 1
+	}
+	return end, quoted
+}
+
+func (p *pipeline) send(b *block) bool {
+	b.result = make(chan *batch, 1)
+	select {
+	case p.order <- b:
+	case <-p.done:
+		return false
+	}
+	select {
+	case p.work <- b:
+	case <-p.done:
+		return false
+	}
+	return true
+}
+
+// rangeResult is the result of scanning a range of the input.
+type rangeResult struct {
+	scan rangeScan
+	err  error
+}
+
+// scanRanges scans the ranges of f from start to end concurrently,
+// and sends a channel of the result of each range in order.
+// The scans run ahead as far as results is buffered.
+// It stops starting scans when quit is closed.
+func (p *pipeline) scanRanges(f seekableFile, start, end int64, results chan<- chan rangeResult, quit <-chan struct{}) {
+	defer close(results)
+	size := int64(parallelBlockSize)
+	for pos := start; pos < end; pos += size {
+		result := make(chan rangeResult, 1)
+		select {
+		case results <- result:
+		case <-quit:
+			return
+		}
+		p.wg.Add(1)
+		go func(pos int64) {
+			defer p.wg.Done()
+			b := make([]byte, parallelBlockSize)
+			m, err := f.ReadAt(b, pos)
+			if err == io.EOF {
+				err = nil
+			}
+			result <- rangeResult{scan: scanRange(b[:m]), err: err}
+		}(pos)
+	}
+}
+
+// splitFile sends blocks of f from start to the end,
+// resynchronizing the boundaries with the parity of quotes.
+// The ranges are scanned for quotes ahead of the blocks being sent,
+// so that the workers start parsing before the whole file is scanned.
+func (p *pipeline) splitFile(f seekableFile, start int64, lines, jobs int) {
+	fi, err := f.Stat()
+	if err != nil {
+		p.send(&block{err: err})
+		return
+	}
+	end := fi.Size()
+
+	results := make(chan chan rangeResult, jobs)
+	quit := make(chan struct{})
+	defer close(quit)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.scanRanges(f, start, end, results, quit)
+	}()
+
+	size := int64(parallelBlockSize)
+	prev, prevLines := start, lines
+	parity := 0
+	i := 0
+	for result := range results {
+		r := <-result
+		if r.err != nil {
+			p.send(&block{err: r.err})
+			return
+		}
+		s := r.scan
+		if i > 0 && s.first[parity] != -1 {
+			next := start + int64(i)*size + int64(s.first[parity])
+			r := io.NewSectionReader(f, prev, next-prev)
+			if !p.send(&block{r: r, lines: prevLines}) {
+				return
+			}
+			prev, prevLines = next, lines+s.lines[parity]
+		}
+		parity ^= s.quotes & 1
+		lines += s.newlines
+		i++
+	}
+	if prev < end {
+		p.send(&block{r: io.NewSectionReader(f, prev, end-prev), lines: prevLines})
+	}
+}
+
+// chunk is a part of a stream read by readStream.
+type chunk struct {
+	b   []byte
+	err error
+}
+
+// readStream sends chunks of r read sequentially until an error.
+// It is not waited by stop, because a read of a stream like a pipe
+// may block until more input arrives, and it only touches r.
+func (p *pipeline) readStream(r io.Reader, chunks chan<- chunk) {
+	for {
+		b := make([]byte, parallelBlockSize)
+		n, err := io.ReadFull(r, b)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		select {
+		case chunks <- chunk{b: b[:n], err: err}:
+		case <-p.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// splitStream sends blocks of r read sequentially.
+// Each byte is scanned for quotes only once even if a record spans chunks.
+func (p *pipeline) splitStream(r io.Reader, lines int) {
+	chunks := make(chan chunk)
+	go p.readStream(r, chunks)
+
+	var buf []byte
+	scanned, end := 0, -1
+	quoted := false
+	for {
+		var c chunk
+		select {
+		case c = <-chunks:
+		case <-p.done:
+			return
+		}
+		if c.err != nil && c.err != io.EOF {
+			p.send(&block{err: c.err})
+			return
+		}
+		buf = append(buf, c.b...)
+
+		e, q := lastRecordEnd(buf[scanned:], quoted)
+		if e != -1 {
+			end = scanned + e
+		}
+		scanned, quoted = len(buf), q
+		if c.err == io.EOF {
+			end = len(buf)
+		}
+		if end > 0 {
+			if !p.send(&block{r: bytes.NewReader(buf[:end]), lines: lines}) {
+				return
+			}
+			lines += bytes.Count(buf[:end], []byte{'\n'})
+			buf = buf[end:]
+			scanned -= end
+			end = -1
+		}
+		if c.err == io.EOF {
+			return
+		}
+	}
+}
+
+// next returns the next record and its line in the order of the input.
+// It stops waiting for the workers when ctx is done.
+func (p *pipeline) next(ctx context.Context) ([]string, int, error) {
+	for {
+		if p.batch != nil {
+			if p.i < len(p.batch.records) {
+				p.i++
+				return p.batch.records[p.i-1], p.batch.lines[p.i-1], nil
+			}
+			if p.batch.err != nil {
+				return nil, 0, p.batch.err
+			}
+		}
+		var b *block
+		var ok bool
+		select {
+		case b, ok = <-p.order:
+			if !ok {
+				return nil, 0, io.EOF
+			}
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+		select {
+		case p.batch = <-b.result:
+			p.i = 0
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+}
+
+// stop stops the pipeline, and waits for the running workers,
+// the splitter and its scans of the input.
+func (p *pipeline) stop() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+// startPipeline starts parsing the rest of the input in c.jobs goroutines.
+func (c *CSVScanner) startPipeline() *pipeline {
+	p := &pipeline{
+		order: make(chan *block, 2*c.jobs),
+		work:  make(chan *block),
+		done:  make(chan struct{}),
+	}
+
+	comma := c.reader.comma
+	fieldsPerRecord := c.reader.fieldsPerRecord
+	var columns []int
+	if s, ok := c.selector.(columnSelector); ok {
+		columns = s.columns()
+	}
+	parse := func(b *block) *batch {
+		if b.err != nil {
+			return &batch{err: b.err}
+		}
+		r := newFieldReader(b.r)
+		r.setComma(comma)
+		r.fieldsPerRecord = fieldsPerRecord
+		if columns != nil {
+			r.setColumns(columns)
+		}
+		bt := &batch{}
+		for {
+			record, err := r.Read()
+			if err == nil {
+				record, err = c.selectRecord(record)
+			}
+			if err != nil {
+				if e, ok := err.(*csv.ParseError); ok {
+					e.StartLine += b.lines
+					e.Line += b.lines
+				}
+				if err != io.EOF {
+					bt.err = err
+				}
+				return bt
+			}
+			bt.records = append(bt.records, record)
+			bt.lines = append(bt.lines, r.line+b.lines)
+		}
+	}
+	p.wg.Add(c.jobs)
+	for i := 0; i < c.jobs; i++ {
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case b, ok := <-p.work:
+					if !ok {
+						return
+					}
+					b.result <- parse(b)
+				case <-p.done:
+					return
+				}
+			}
+		}()
+	}
+
+	lines := c.reader.lines()
+	f, seekable := c.src.(seekableFile)
+	var start int64
+	if seekable {
+		fi, err := f.Stat()
+		seekable = err == nil && fi.Mode().IsRegular()
+	}
+	if seekable {
+		pos, err := f.Seek(0, io.SeekCurrent)
+		seekable = err == nil
+		start = pos - int64(c.reader.br.Buffered())
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(p.order)
+		defer close(p.work)
+		if seekable {
+			p.splitFile(f, start, lines, c.jobs)
+		} else {
+			p.splitStream(c.reader.br, lines)
+		}
+	}()
+	return p
+}
@@ -0,0 +1,194 @@
+package csvp
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var scanRangeTests = []struct {
+	src   string
+	first [2]int
+	lines [2]int
+}{
+	{src: "", first: [2]int{-1, -1}},
+	{src: "a,b\nc,d\n", first: [2]int{4, -1}, lines: [2]int{1, 0}},
+	{src: "a\"b\nc\"d\ne\n", first: [2]int{8, 4}, lines: [2]int{2, 1}},
+	{src: "\"\"\na\n", first: [2]int{3, -1}, lines: [2]int{1, 0}},
+}
+
+func TestScanRange(t *testing.T) {
+	for _, test := range scanRangeTests {
+		s := scanRange([]byte(test.src))
+		if s.first != test.first || s.lines != test.lines {
+			t.Errorf("scanRange(%q) = %v %v, want %v %v",
+				test.src, s.first, s.lines, test.first, test.lines)
+		}
+	}
+}
+
+var lastRecordEndTests = []struct {
+	src    string
+	quoted bool
+	end    int
+	after  bool
+}{
+	{src: "", end: -1},
+	{src: "a,b", end: -1},
+	{src: "a,b\nc,d", end: 4},
+	{src: "a,b\n\"c\nd", end: 4, after: true},
+	{src: "a,b\n\"c\nd\"\ne", end: 10},
+	{src: "a,\"\"\"\nb\"\n", end: 9},
+	{src: "c\nd\"\ne", quoted: true, end: 5},
+	{src: "c\nd", quoted: true, end: -1, after: true},
+	{src: "\"", end: -1, after: true},
+}
+
+func TestLastRecordEnd(t *testing.T) {
+	for _, test := range lastRecordEndTests {
+		end, after := lastRecordEnd([]byte(test.src), test.quoted)
+		if end != test.end || after != test.after {
+			t.Errorf("lastRecordEnd(%q, %v) = %d, %v, want %d, %v",
+				test.src, test.quoted, end, after, test.end, test.after)
+		}
+	}
+}
+
+func TestLastRecordEndIncremental(t *testing.T) {
+	src := []byte("a,\"b\n\"\"c\"\nd,\"e\n\nf\"\ng,h")
+	expect, expectQuoted := lastRecordEnd(src, false)
+	for n := 1; n <= len(src); n++ {
+		end, quoted := -1, false
+		for i := 0; i < len(src); i += n {
+			j := i + n
+			if j > len(src) {
+				j = len(src)
+			}
+			e, q := lastRecordEnd(src[i:j], quoted)
+			if e != -1 {
+				end = i + e
+			}
+			quoted = q
+		}
+		if end != expect || quoted != expectQuoted {
+			t.Errorf("n=%d: got %d, %v, want %d, %v",
+				n, end, quoted, expect, expectQuoted)
+		}
+	}
+}
+
+var jobsSources = []string{
+	"",
+	"a,b,c\n",
+	"a,b,c\n1,2,3\n4,5,6\n7,8,9",
+	"a,b,c\r\n1,2,3\r\n\r\n4,5,6\r\n",
+	"a,b,c\n\"1\n2\",3,\"4\"\"\n5\"\n6,\"7,\n8\",9\n10,11,12\n",
+	"\"a\nb\",c,d\n1,2,3\n4,5,6\n",
+	"a,b,c\n1,2,3\n4,5\n6,7,8\n",
+	"a,b,c\n1,2,3\n4,\"5\n6\",7,8\n9,10,11\n",
+	"a,b,c\n1,2,3\n4,5\"6,7\n",
+	"a,b,c\n1,2,3\n4,\"5,6\n",
+}
+
+func scanAll(c *CSVScanner) ([][]string, []int, string) {
+	var records [][]string
+	var lines []int
+	for c.Scan() {
+		records = append(records, c.record)
+		lines = append(lines, c.Line())
+	}
+	if err := c.Err(); err != nil {
+		return records, lines, err.Error()
+	}
+	return records, lines, ""
+}
+
+func TestScanWithJobs(t *testing.T) {
+	defer func(size int) { parallelBlockSize = size }(parallelBlockSize)
+
+	f, err := ioutil.TempFile("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	for _, size := range []int{1, 5, 16, 1 << 20} {
+		parallelBlockSize = size
+		for _, src := range jobsSources {
+			for _, selector := range []Selector{NewAll(), NewIndexes("3,1"), NewHeaders("b")} {
+				expect := NewCSVScanner(selector, strings.NewReader(src))
+				expectRecords, expectLines, expectErr := scanAll(expect)
+
+				c := NewCSVScanner(selector, strings.NewReader(src))
+				c.SetJobs(4)
+				actualRecords, actualLines, actualErr := scanAll(c)
+				if !reflect.DeepEqual(actualRecords, expectRecords) || !reflect.DeepEqual(actualLines, expectLines) || actualErr != expectErr {
+					t.Errorf("stream: size=%d src=%q:\ngot:\n%q %v %q\nwant:\n%q %v %q",
+						size, src,
+						actualRecords, actualLines, actualErr, expectRecords, expectLines, expectErr)
+				}
+
+				if err := f.Truncate(0); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := f.WriteAt([]byte(src), 0); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := f.Seek(0, 0); err != nil {
+					t.Fatal(err)
+				}
+				c.InitializeReader(f)
+				actualRecords, actualLines, actualErr = scanAll(c)
+				if !reflect.DeepEqual(actualRecords, expectRecords) || !reflect.DeepEqual(actualLines, expectLines) || actualErr != expectErr {
+					t.Errorf("file: size=%d src=%q:\ngot:\n%q %v %q\nwant:\n%q %v %q",
+						size, src,
+						actualRecords, actualLines, actualErr, expectRecords, expectLines, expectErr)
+				}
+			}
+		}
+	}
+}
+
+// slowFile is a file whose ReadAt takes time,
+// so that the scans of ranges are running when the pipeline is stopped.
+type slowFile struct {
+	*os.File
+}
+
+func (f slowFile) ReadAt(b []byte, off int64) (int, error) {
+	time.Sleep(time.Millisecond)
+	return f.File.ReadAt(b, off)
+}
+
+func TestPipelineStop(t *testing.T) {
+	defer func(size int) { parallelBlockSize = size }(parallelBlockSize)
+	parallelBlockSize = 16
+
+	f, err := ioutil.TempFile("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.WriteString("a,b,c\n" + strings.Repeat("1,\"2\n\",3\n", 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	n := runtime.NumGoroutine()
+	c := NewCSVScanner(NewAll(), slowFile{f})
+	c.SetJobs(4)
+	for i := 0; i < 3 && c.Scan(); i++ {
+	}
+	c.InitializeReader(strings.NewReader(""))
+	if m := runtime.NumGoroutine(); m > n {
+		t.Errorf("%d goroutines remain after the pipeline is stopped", m-n)
+	}
+}
@@ -0,0 +1,81 @@
+package csvp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SelectorFunc returns a Selector which selects columns by expr.
+type SelectorFunc func(expr string) (Selector, error)
+
+var (
+	selectorsMu sync.RWMutex
+	selectors   = make(map[string]SelectorFunc)
+)
+
+func init() {
+	RegisterSelector("all", func(expr string) (Selector, error) {
+		if expr != "" {
+			return nil, fmt.Errorf("%q: all takes no expression", expr)
+		}
+		return NewAll(), nil
+	})
+	RegisterSelector("indexes", func(expr string) (Selector, error) {
+		return NewIndexes(expr), nil
+	})
+	RegisterSelector("headers", func(expr string) (Selector, error) {
+		return NewHeaders(expr), nil
+	})
+}
+
+// RegisterSelector makes f available by scheme in NewSelector.
+// The built-in schemes are "all", "indexes" and "headers".
+// It panics if scheme is empty or has a colon, f is nil,
+// or it is called twice with the same scheme.
+func RegisterSelector(scheme string, f SelectorFunc) {
+	selectorsMu.Lock()
+	defer selectorsMu.Unlock()
+	if scheme == "" || strings.Contains(scheme, ":") {
+		panic(fmt.Sprintf("csvp: invalid selector scheme %q", scheme))
+	}
+	if f == nil {
+		panic("csvp: RegisterSelector function is nil")
+	}
+	if _, ok := selectors[scheme]; ok {
+		panic(fmt.Sprintf("csvp: RegisterSelector called twice for %q", scheme))
+	}
+	selectors[scheme] = f
+}
+
+// NewSelector returns a Selector from spec like "indexes:1,3-5",
+// by the function registered with the scheme before the first colon.
+// The rest of spec is passed to the function as the expression.
+func NewSelector(spec string) (Selector, error) {
+	i := strings.Index(spec, ":")
+	if i == -1 {
+		return nil, fmt.Errorf("%q: no selector scheme", spec)
+	}
+	scheme, expr := spec[:i], spec[i+1:]
+
+	selectorsMu.RLock()
+	f, ok := selectors[scheme]
+	selectorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%q: unknown selector scheme", scheme)
+	}
+	return f(expr)
+}
+
+// Schemes returns the sorted list of the registered schemes.
+func Schemes() []string {
+	selectorsMu.RLock()
+	defer selectorsMu.RUnlock()
+	a := make([]string, 0, len(selectors))
+	for scheme := range selectors {
+		a = append(a, scheme)
+	}
+	sort.Strings(a)
+	return a
+}
@@ -0,0 +1,98 @@
+package csvp
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewSelector(t *testing.T) {
+	tests := []struct {
+		spec   string
+		expect Selector
+	}{
+		{"all:", NewAll()},
+		{"indexes:3,1-2", NewIndexes("3,1-2")},
+		{"indexes:", NewIndexes("")},
+		{"headers:a,b\\,c", NewHeaders("a,b\\,c")},
+		{"headers:a:b", NewHeaders("a:b")},
+	}
+	for _, test := range tests {
+		actual, err := NewSelector(test.spec)
+		if err != nil {
+			t.Errorf("NewSelector(%q) returns %q, want nil", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, test.expect) {
+			t.Errorf("NewSelector(%q) = %#v, want %#v",
+				test.spec, actual, test.expect)
+		}
+	}
+}
+
+func TestNewSelectorError(t *testing.T) {
+	for _, spec := range []string{"", "indexes", "all:1", "unknown:1", ":1"} {
+		if _, err := NewSelector(spec); err == nil {
+			t.Errorf("NewSelector(%q) returns nil, want error", spec)
+		}
+	}
+}
+
+func init() {
+	RegisterSelector("test-upper", func(expr string) (Selector, error) {
+		return NewHeaders(strings.ToUpper(expr)), nil
+	})
+}
+
+func TestRegisterSelector(t *testing.T) {
+	s, err := NewSelector("test-upper:b")
+	if err != nil {
+		t.Fatalf("NewSelector returns %q, want nil", err)
+	}
+
+	c := NewCSVScanner(s, strings.NewReader("A,B\n1,2\n"))
+	records := []string{}
+	for c.Scan() {
+		records = append(records, c.Text())
+	}
+	if expect := []string{"2"}; !reflect.DeepEqual(records, expect) {
+		t.Errorf("got %q, want %q", records, expect)
+	}
+
+	schemes := Schemes()
+	if !sort.StringsAreSorted(schemes) {
+		t.Errorf("Schemes() = %q, want sorted", schemes)
+	}
+	for _, scheme := range []string{"all", "headers", "indexes", "test-upper"} {
+		i := sort.SearchStrings(schemes, scheme)
+		if i == len(schemes) || schemes[i] != scheme {
+			t.Errorf("Schemes() = %q, want to contain %q", schemes, scheme)
+		}
+	}
+}
+
+func TestRegisterSelectorPanic(t *testing.T) {
+	f := func(expr string) (Selector, error) {
+		return NewAll(), nil
+	}
+	tests := []struct {
+		scheme string
+		f      SelectorFunc
+	}{
+		{"all", f},
+		{"", f},
+		{"a:b", f},
+		{"test-nil", nil},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterSelector(%q) does not panic", test.scheme)
+				}
+			}()
+			RegisterSelector(test.scheme, test.f)
+		}()
+	}
+}
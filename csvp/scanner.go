@@ -0,0 +1,246 @@
+package csvp
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Transform transforms selected records.
+//
+// ParseHeaders is called with the selected headers of each input,
+// and returns the headers of the transformed records.
+type Transform interface {
+	ParseHeaders(headers []string) ([]string, error)
+	Transform(record []string) ([]string, error)
+}
+
+// CSVScanner reads CSV records and selects columns of them by a Selector.
+//
+// Like bufio.Scanner, successive calls to Scan step through the records,
+// and Err returns the first error except io.EOF.
+// The first record is the header row unless the Selector drops it.
+//
+// With SetJobs, the records are parsed, selected and transformed
+// in several goroutines, and Scan returns them in the original order.
+type CSVScanner struct {
+	outputDelimiter string
+	buf             []byte
+	record          []string
+	line            int
+	headers         []string
+	err             error
+	parsedHeaders   bool
+	selector        Selector
+	transforms      []Transform
+	reader          *fieldReader
+	src             io.Reader
+	jobs            int
+	pipeline        *pipeline
+}
+
+// NewCSVScanner returns a CSVScanner which selects columns of r by s.
+// The delimiter is a comma and the output delimiter is a tab by default.
+func NewCSVScanner(s Selector, r io.Reader) *CSVScanner {
+	return &CSVScanner{
+		outputDelimiter: "\t",
+		selector:        s,
+		reader:          newFieldReader(r),
+		src:             r,
+		jobs:            1,
+	}
+}
+
+// SetDelimiter sets the field delimiter of the input.
+func (c *CSVScanner) SetDelimiter(ch rune) {
+	c.reader.setComma(ch)
+}
+
+// SetOutputDelimiter sets the delimiter used by Text and Bytes.
+func (c *CSVScanner) SetOutputDelimiter(s string) {
+	c.outputDelimiter = s
+}
+
+// SetJobs sets the number of goroutines to parse records after the headers.
+// If n > 1, Select of the Selector and Transform of the transforms are
+// called concurrently from n goroutines, so they must be safe for
+// concurrent use, and must not modify shared state such as the records
+// they are given.
+func (c *CSVScanner) SetJobs(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.jobs = n
+}
+
+// AddTransform adds t to be applied to each selected record in order.
+// t may be called concurrently; see SetJobs.
+func (c *CSVScanner) AddTransform(t Transform) {
+	c.transforms = append(c.transforms, t)
+}
+
+// InitializeReader resets c to read r.
+// The headers of r are parsed again by the Selector.
+func (c *CSVScanner) InitializeReader(r io.Reader) {
+	ch := c.reader.comma
+	c.reader = newFieldReader(r)
+	c.reader.setComma(ch)
+	c.src = r
+	c.stopPipeline()
+	c.parsedHeaders = false
+	c.err = nil
+	c.record = nil
+	c.line = 0
+	c.headers = nil
+}
+
+// Err returns the first error except io.EOF encountered by c.
+func (c *CSVScanner) Err() error {
+	if c.err == io.EOF {
+		return nil
+	}
+	return c.err
+}
+
+// Bytes returns the selected fields joined by the output delimiter.
+// The underlying array may point to data that will be overwritten
+// by a subsequent call to Bytes.
+func (c *CSVScanner) Bytes() []byte {
+	c.buf = c.buf[:0]
+	for i, field := range c.record {
+		if i > 0 {
+			c.buf = append(c.buf, c.outputDelimiter...)
+		}
+		c.buf = append(c.buf, field...)
+	}
+	return c.buf
+}
+
+// Selector returns the Selector of c.
+func (c *CSVScanner) Selector() Selector {
+	return c.selector
+}
+
+// Record returns the selected fields of the most recent record.
+// The slice may be overwritten by the next call to Scan.
+func (c *CSVScanner) Record() []string {
+	return c.record
+}
+
+// Line returns the line number where the most recent record starts.
+// Line numbers start at 1 and count the lines of the input.
+func (c *CSVScanner) Line() int {
+	return c.line
+}
+
+// Headers returns the selected headers of the input,
+// or nil until the header row is scanned.
+// If the Selector is a HeaderNamer, they are its OutputHeaders.
+func (c *CSVScanner) Headers() []string {
+	return c.headers
+}
+
+// Text returns the selected fields joined by the output delimiter.
+func (c *CSVScanner) Text() string {
+	return strings.Join(c.record, c.outputDelimiter)
+}
+
+func (c *CSVScanner) stopPipeline() {
+	if c.pipeline != nil {
+		c.pipeline.stop()
+		c.pipeline = nil
+	}
+}
+
+// selectRecord returns the selected and transformed record.
+func (c *CSVScanner) selectRecord(record []string) ([]string, error) {
+	record, err := c.selector.Select(record)
+	for i := 0; err == nil && i < len(c.transforms); i++ {
+		record, err = c.transforms[i].Transform(record)
+	}
+	return record, err
+}
+
+// Scan advances c to the next record, which is then available through
+// Record, Text and Bytes. It returns false when the scan stops,
+// either by reaching the end of the input or an error.
+func (c *CSVScanner) Scan() bool {
+	return c.ScanContext(context.Background())
+}
+
+// ScanContext is like Scan, but stops the scan when ctx is done.
+// The cancellation is checked between records,
+// and Err returns ctx.Err() after the scan is stopped by ctx.
+func (c *CSVScanner) ScanContext(ctx context.Context) bool {
+	if c.err != nil {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		c.stopPipeline()
+		c.err = err
+		c.record = nil
+		return false
+	}
+
+	if c.parsedHeaders && c.jobs > 1 {
+		if c.pipeline == nil {
+			c.pipeline = c.startPipeline()
+		}
+		record, line, err := c.pipeline.next(ctx)
+		if err != nil {
+			c.stopPipeline()
+			c.err = err
+			c.record = nil
+			return false
+		}
+		c.record = record
+		c.line = line
+		return true
+	}
+
+	record, err := c.reader.Read()
+	if err != nil {
+		c.err = err
+		c.record = nil
+		return false
+	}
+	c.line = c.reader.line
+
+	if !c.parsedHeaders {
+		err = c.selector.ParseHeaders(record)
+		if err != nil {
+			c.err = err
+			c.record = nil
+			return false
+		}
+		c.headers, err = outputHeaders(c.selector, record)
+		for i := 0; err == nil && i < len(c.transforms); i++ {
+			c.headers, err = c.transforms[i].ParseHeaders(c.headers)
+		}
+		if err != nil {
+			c.err = err
+			c.record = nil
+			return false
+		}
+		c.parsedHeaders = true
+		if s, ok := c.selector.(columnSelector); ok && s.columns() != nil {
+			c.reader.setColumns(s.columns())
+		}
+
+		if c.selector.DropHeaders() {
+			return c.ScanContext(ctx)
+		}
+		c.record = c.headers
+		return true
+	}
+
+	record, err = c.selectRecord(record)
+	if err != nil {
+		c.err = err
+		c.record = nil
+		return false
+	}
+	c.record = record
+
+	return true
+}
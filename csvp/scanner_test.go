@@ -1,6 +1,7 @@
-package main
+package csvp
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -17,7 +18,7 @@ func ppResults(results []ScanResult) string {
 	lines := make([]string, len(results))
 	for i := 0; i < len(results); i++ {
 		lines[i] = fmt.Sprintf("%#v", results[i])
-		lines[i] = strings.TrimPrefix(lines[i], "main.ScanResult")
+		lines[i] = strings.TrimPrefix(lines[i], "csvp.ScanResult")
 	}
 	return strings.Join(lines, "\n")
 }
@@ -339,3 +340,124 @@ a,b,c
 			actual, expect)
 	}
 }
+
+func TestLine(t *testing.T) {
+	src := "a,b\n1,2\n\n3,\"4\n5\"\r\n6,7\n"
+	for _, dropHeaders := range []bool{false, true} {
+		c := NewCSVScanner(&DummyAll{dropHeaders: dropHeaders}, strings.NewReader(src))
+		expect := []int{2, 4, 6}
+		if !dropHeaders {
+			expect = append([]int{1}, expect...)
+		}
+		actual := []int{}
+		for c.Scan() {
+			actual = append(actual, c.Line())
+		}
+		if err := c.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("dropHeaders=%v: got %v, want %v",
+				dropHeaders, actual, expect)
+		}
+	}
+}
+
+func TestScanContext(t *testing.T) {
+	src := strings.Repeat("1,2,3\n", 100)
+	for _, jobs := range []int{1, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := NewCSVScanner(&DummyAll{dropHeaders: true}, strings.NewReader(src))
+		c.SetJobs(jobs)
+		n := 0
+		for c.ScanContext(ctx) {
+			n++
+			if n == 10 {
+				cancel()
+			}
+		}
+		if n != 10 {
+			t.Errorf("jobs=%d: scanned %d records after cancel, want %d",
+				jobs, n, 10)
+		}
+		if err := c.Err(); err != context.Canceled {
+			t.Errorf("jobs=%d: Err() = %v, want %v",
+				jobs, err, context.Canceled)
+		}
+		if c.ScanContext(context.Background()) {
+			t.Errorf("jobs=%d: Scan should return false after cancel", jobs)
+		}
+		cancel()
+	}
+}
+
+type DummyTransform struct {
+}
+
+func (d *DummyTransform) ParseHeaders(headers []string) ([]string, error) {
+	return append(headers, "length"), nil
+}
+
+func (d *DummyTransform) Transform(record []string) ([]string, error) {
+	return append(record, fmt.Sprint(len(record))), nil
+}
+
+func TestAddTransform(t *testing.T) {
+	r := strings.NewReader(`
+a,b,c
+1,2,3
+`[1:])
+	for _, dropHeaders := range []bool{false, true} {
+		r.Seek(0, 0)
+		c := NewCSVScanner(&DummyAll{dropHeaders: dropHeaders}, r)
+		c.AddTransform(&DummyTransform{})
+
+		expect := []string{"a\tb\tc\tlength", "1\t2\t3\t3"}
+		if dropHeaders {
+			expect = expect[1:]
+		}
+		actual := []string{}
+		for c.Scan() {
+			actual = append(actual, c.Text())
+		}
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("dropHeaders=%v:\ngot: %q\nwant: %q",
+				dropHeaders, actual, expect)
+		}
+	}
+}
+
+func benchmarkSource(rows int) string {
+	b := &strings.Builder{}
+	b.WriteString("id,name,price,stock,date\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(b, "%d,item%d,%d.50,%d,2017-01-02\n", i, i, i%1000, i%7)
+	}
+	return b.String()
+}
+
+func benchmarkScan(b *testing.B, selector Selector) {
+	src := benchmarkSource(10000)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := NewCSVScanner(selector, strings.NewReader(src))
+		for c.Scan() {
+		}
+		if err := c.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanAll(b *testing.B) {
+	benchmarkScan(b, NewAll())
+}
+
+func BenchmarkScanIndexes(b *testing.B) {
+	benchmarkScan(b, NewIndexes("1,3"))
+}
+
+func BenchmarkScanHeaders(b *testing.B) {
+	benchmarkScan(b, NewHeaders("name,price"))
+}
@@ -0,0 +1,340 @@
+package csvp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Selector selects columns of records.
+//
+// ParseHeaders is called with the header row of each input
+// before Select is called with the records.
+// If DropHeaders returns true, the header row is not returned as a record.
+type Selector interface {
+	DropHeaders() bool
+	ParseHeaders(headers []string) error
+	Select(record []string) ([]string, error)
+}
+
+// HeaderNamer is implemented by selectors which report
+// the names of the output columns.
+//
+// OutputHeaders returns the names of the columns selected
+// after ParseHeaders, in the order of the output.
+// The scanner uses them as the headers instead of
+// the selected fields of the header row.
+// The returned slice should not be modified.
+type HeaderNamer interface {
+	OutputHeaders() []string
+}
+
+// outputHeaders returns the names of the columns selected by s
+// from the header row headers, after s.ParseHeaders(headers).
+func outputHeaders(s Selector, headers []string) ([]string, error) {
+	if n, ok := s.(HeaderNamer); ok {
+		names := n.OutputHeaders()
+		return append(make([]string, 0, len(names)), names...), nil
+	}
+	return s.Select(headers)
+}
+
+// All selects all columns. The header row is not dropped.
+type All struct {
+	headers []string
+}
+
+// NewAll returns an All.
+func NewAll() *All {
+	return &All{}
+}
+
+func (a *All) DropHeaders() bool {
+	return false
+}
+
+func (a *All) ParseHeaders(headers []string) error {
+	a.headers = append(a.headers[:0:0], headers...)
+	return nil
+}
+
+// OutputHeaders returns the header row.
+func (a *All) OutputHeaders() []string {
+	return a.headers
+}
+
+func (a *All) Select(record []string) ([]string, error) {
+	return record, nil
+}
+
+var (
+	exprIndexes = regexp.MustCompile(`^(?:\d*-\d*|\d+)(?:,(?:\d*-\d*|\d+))*$`)
+	exprIndex   = regexp.MustCompile(`(?:\d*-\d*|\d+)`)
+	exprRange   = regexp.MustCompile(`^(\d*)-(\d*)$`)
+)
+
+// ParseIndex parses an index numbered from 1.
+func ParseIndex(s string) (index int, err error) {
+	index, err = strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if index == 0 {
+		return 0, fmt.Errorf("indexes are numbered from 1")
+	}
+	return index, nil
+}
+
+// Range is a range of indexes numbered from 1.
+// Last is 0 if the range has no end.
+type Range struct {
+	First int
+	Last  int
+}
+
+// ParseRanges parses a list of indexes and ranges separated by commas,
+// like "1,3-5,-2,7-". The first of a range defaults to 1,
+// and the last of a range defaults to no end.
+// It returns nil if list is empty.
+func ParseRanges(list string) ([]Range, error) {
+	if list == "" {
+		return nil, nil
+	}
+	if !exprIndexes.MatchString(list) {
+		return nil, fmt.Errorf("%q: invalid syntax", list)
+	}
+
+	ranges := make([]Range, 0)
+	for _, rawIndex := range exprIndex.FindAllString(list, -1) {
+		var err error
+		r := Range{First: 1}
+		switch {
+		case exprRange.MatchString(rawIndex):
+			rawRange := exprRange.FindStringSubmatch(rawIndex)
+			if rawRange[1] != "" {
+				r.First, err = ParseIndex(rawRange[1])
+				if err != nil {
+					return nil, err
+				}
+			}
+			if rawRange[2] != "" {
+				r.Last, err = ParseIndex(rawRange[2])
+				if err != nil {
+					return nil, err
+				}
+			}
+		default:
+			r.First, err = ParseIndex(rawIndex)
+			if err != nil {
+				return nil, err
+			}
+			r.Last = r.First
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// Indexes selects columns by indexes and ranges numbered from 1,
+// like "1,3-5,-2,7-". The header row is not dropped.
+type Indexes struct {
+	list    string
+	indexes []int
+	headers []string
+}
+
+// NewIndexes returns an Indexes which selects columns in list.
+// The syntax of list is checked by ParseHeaders.
+func NewIndexes(list string) *Indexes {
+	return &Indexes{
+		list: list,
+	}
+}
+
+func (i *Indexes) DropHeaders() bool {
+	return false
+}
+
+// ParseHeaders resolves the ranges of i with the number of headers.
+// A range which exceeds the headers is truncated,
+// but a single index is selected as an empty column.
+func (i *Indexes) ParseHeaders(headers []string) error {
+	if i.list == "" {
+		i.indexes = make([]int, 0)
+		i.headers = make([]string, 0)
+		return nil
+	}
+	if !exprIndexes.MatchString(i.list) {
+		return fmt.Errorf("%q: invalid syntax", i.list)
+	}
+
+	i.indexes = make([]int, 0)
+	for _, rawIndex := range exprIndex.FindAllString(i.list, -1) {
+		var err error
+		switch {
+		case exprRange.MatchString(rawIndex):
+			first, last := 1, len(headers)
+			rawRange := exprRange.FindStringSubmatch(rawIndex)
+			if rawRange[1] != "" {
+				first, err = ParseIndex(rawRange[1])
+				if err != nil {
+					return err
+				}
+			}
+			if rawRange[2] != "" {
+				last, err = ParseIndex(rawRange[2])
+				if err != nil {
+					return err
+				}
+			}
+			for index := first; index <= last && index <= len(headers); index++ {
+				i.indexes = append(i.indexes, index-1)
+			}
+		default:
+			index, err := ParseIndex(rawIndex)
+			if err != nil {
+				return err
+			}
+			i.indexes = append(i.indexes, index-1)
+		}
+	}
+	i.headers = pick(headers, i.indexes)
+	return nil
+}
+
+// OutputHeaders returns the selected headers.
+// An index which exceeds the headers is named an empty string.
+func (i *Indexes) OutputHeaders() []string {
+	return i.headers
+}
+
+func (i *Indexes) Select(record []string) ([]string, error) {
+	return pick(record, i.indexes), nil
+}
+
+// pick returns the fields of record at columns.
+func pick(record []string, columns []int) []string {
+	a := make([]string, len(columns))
+	for i, column := range columns {
+		if column >= 0 && column < len(record) {
+			a[i] = record[column]
+		}
+	}
+	return a
+}
+
+var (
+	exprHeader    = regexp.MustCompile(`(?:[^,\\]|\\.)*`)
+	exprBackslash = regexp.MustCompile(`\\(.)`)
+	exprTrailing  = regexp.MustCompile(`\\+$`)
+)
+
+// Headers selects columns by header names.
+// The header row is dropped.
+type Headers struct {
+	indexes []int
+	headers []string
+}
+
+// ParseHeaderList splits a list of headers separated by commas.
+// A comma or a backslash in a header can be escaped by a backslash,
+// and a trailing unescaped backslash is dropped.
+// It returns an empty slice if list is empty.
+func ParseHeaderList(list string) []string {
+	list = dropTrailingBackslash(list)
+	if list == "" {
+		return []string{}
+	}
+
+	headers := exprHeader.FindAllString(list, -1)
+	for i := 0; i < len(headers); i++ {
+		headers[i] = exprBackslash.ReplaceAllString(headers[i], "$1")
+	}
+	return headers
+}
+
+// Unescape removes the backslashes in a header
+// in the same way as ParseHeaderList, without splitting it at commas.
+func Unescape(s string) string {
+	return exprBackslash.ReplaceAllString(dropTrailingBackslash(s), "$1")
+}
+
+func dropTrailingBackslash(s string) string {
+	return exprTrailing.ReplaceAllStringFunc(s, func(s string) string {
+		return strings.Repeat(`\\`, len(s)/2)
+	})
+}
+
+// NewHeaders returns a Headers which selects columns in list.
+// Headers in list are separated by commas,
+// and a comma or a backslash in a header can be escaped by a backslash.
+func NewHeaders(list string) *Headers {
+	return &Headers{
+		headers: ParseHeaderList(list),
+	}
+}
+
+func (h *Headers) DropHeaders() bool {
+	return true
+}
+
+// ParseHeaders resolves the headers of h with the header row.
+// It returns an error if the header row has duplicated headers.
+func (h *Headers) ParseHeaders(headers []string) error {
+	indexMap := make(map[string]int)
+	for i, header := range headers {
+		if _, ok := indexMap[header]; ok {
+			return fmt.Errorf("%q: duplicated header", header)
+		}
+		indexMap[header] = i
+	}
+
+	h.indexes = make([]int, len(h.headers))
+	for i, header := range h.headers {
+		if index, ok := indexMap[header]; ok {
+			h.indexes[i] = index
+		} else {
+			h.indexes[i] = -1
+		}
+	}
+	return nil
+}
+
+// Missing returns the headers not found by the last ParseHeaders.
+// Missing headers are selected as empty columns.
+func (h *Headers) Missing() []string {
+	a := make([]string, 0)
+	for i, index := range h.indexes {
+		if index == -1 {
+			a = append(a, h.headers[i])
+		}
+	}
+	return a
+}
+
+// OutputHeaders returns the headers of h.
+// A missing header is empty like its column,
+// so that it is not mistaken for a header of the input.
+func (h *Headers) OutputHeaders() []string {
+	if h.indexes == nil {
+		return h.headers
+	}
+	a := make([]string, len(h.indexes))
+	for i, index := range h.indexes {
+		if index != -1 {
+			a[i] = h.headers[i]
+		}
+	}
+	return a
+}
+
+func (h *Headers) Select(record []string) ([]string, error) {
+	a := make([]string, len(h.indexes))
+	for i, index := range h.indexes {
+		if index != -1 {
+			a[i] = record[index]
+		}
+	}
+	return a, nil
+}
@@ -1,4 +1,4 @@
-package main
+package csvp
 
 import (
 	"fmt"
@@ -287,6 +287,56 @@ func TestSelectIndexes(t *testing.T) {
 	}
 }
 
+var unescapeTests = []struct {
+	src string
+	dst string
+}{
+	{src: "", dst: ""},
+	{src: "name", dst: "name"},
+	{src: `a\,b`, dst: "a,b"},
+	{src: `a\\b`, dst: `a\b`},
+	{src: `a\bc\de`, dst: "abcde"},
+	{src: `b\`, dst: "b"},
+	{src: `b\\\`, dst: `b\`},
+}
+
+func TestUnescape(t *testing.T) {
+	for _, test := range unescapeTests {
+		expect := test.dst
+		actual := Unescape(test.src)
+		if actual != expect {
+			t.Errorf("Unescape(%q) = %q, want %q",
+				test.src, actual, expect)
+		}
+	}
+}
+
+var parseIndexTests = []struct {
+	src   string
+	index int
+	valid bool
+}{
+	{src: "1", index: 1, valid: true},
+	{src: "12", index: 12, valid: true},
+	{src: "0", valid: false},
+	{src: "", valid: false},
+	{src: "a", valid: false},
+}
+
+func TestParseIndex(t *testing.T) {
+	for _, test := range parseIndexTests {
+		index, err := ParseIndex(test.src)
+		switch {
+		case test.valid && err != nil:
+			t.Errorf("ParseIndex(%q) returns %q, want nil", test.src, err)
+		case !test.valid && err == nil:
+			t.Errorf("ParseIndex(%q) returns nil, want err", test.src)
+		case index != test.index:
+			t.Errorf("ParseIndex(%q) = %d, want %d", test.src, index, test.index)
+		}
+	}
+}
+
 var newHeadersTests = []struct {
 	list    string
 	headers []string
@@ -543,3 +593,29 @@ func TestSelectHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestOutputHeaders(t *testing.T) {
+	headers := []string{"a", "b", "c"}
+	tests := []struct {
+		selector Selector
+		expect   []string
+	}{
+		{NewAll(), []string{"a", "b", "c"}},
+		{NewIndexes("3,1-2"), []string{"c", "a", "b"}},
+		{NewIndexes("2-,5"), []string{"b", "c", ""}},
+		{NewIndexes(""), []string{}},
+		{NewHeaders("c,x,a"), []string{"c", "", "a"}},
+		{NewHeaders(""), []string{}},
+	}
+	for _, test := range tests {
+		if err := test.selector.ParseHeaders(headers); err != nil {
+			t.Errorf("%#v: ParseHeaders returns %q", test.selector, err)
+			continue
+		}
+		actual := test.selector.(HeaderNamer).OutputHeaders()
+		if !reflect.DeepEqual(actual, test.expect) {
+			t.Errorf("%#v: OutputHeaders() = %q, want %q",
+				test.selector, actual, test.expect)
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenColumn
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func isIdentRune(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
+
+type lexer struct {
+	s   string
+	pos int
+}
+
+func (l *lexer) scanWhile(f func(ch rune) bool) string {
+	i := l.pos
+	for l.pos < len(l.s) {
+		ch, size := utf8.DecodeRuneInString(l.s[l.pos:])
+		if !f(ch) {
+			break
+		}
+		l.pos += size
+	}
+	return l.s[i:l.pos]
+}
+
+func (l *lexer) scan() (token, error) {
+	l.scanWhile(unicode.IsSpace)
+	if l.pos >= len(l.s) {
+		return token{kind: tokenEOF, pos: l.pos}, nil
+	}
+
+	i := l.pos
+	ch, size := utf8.DecodeRuneInString(l.s[i:])
+	switch {
+	case ch >= '0' && ch <= '9' || ch == '.':
+		value := l.scanWhile(func(ch rune) bool {
+			return ch >= '0' && ch <= '9' || ch == '.'
+		})
+		return token{kind: tokenNumber, value: value, pos: i}, nil
+	case ch == '"':
+		b := make([]byte, 0)
+		j := i + 1
+		for ; j < len(l.s) && l.s[j] != '"'; j++ {
+			if l.s[j] == '\\' && j+1 < len(l.s) {
+				j++
+			}
+			b = append(b, l.s[j])
+		}
+		if j >= len(l.s) {
+			return token{}, fmt.Errorf("%d: unterminated string", i+1)
+		}
+		l.pos = j + 1
+		return token{kind: tokenString, value: string(b), pos: i}, nil
+	case strings.HasPrefix(l.s[i:], "${"):
+		j := strings.IndexByte(l.s[i:], '}')
+		if j == -1 {
+			return token{}, fmt.Errorf("%d: unterminated column", i+1)
+		}
+		l.pos = i + j + 1
+		return token{kind: tokenColumn, value: l.s[i+2 : i+j], pos: i}, nil
+	case ch == '$':
+		l.pos += size
+		value := l.scanWhile(isIdentRune)
+		if value == "" {
+			return token{}, fmt.Errorf("%d: column name required after $", i+1)
+		}
+		return token{kind: tokenColumn, value: value, pos: i}, nil
+	case isIdentRune(ch):
+		value := l.scanWhile(isIdentRune)
+		return token{kind: tokenIdent, value: value, pos: i}, nil
+	case strings.ContainsRune("+-*/%(),", ch):
+		l.pos += size
+		return token{kind: tokenOperator, value: string(ch), pos: i}, nil
+	}
+	return token{}, fmt.Errorf("%d: unexpected %q", i+1, ch)
+}
+
+type expr interface {
+	eval(record []string) (string, error)
+}
+
+type literalExpr struct {
+	value string
+}
+
+func (e *literalExpr) eval(record []string) (string, error) {
+	return e.value, nil
+}
+
+type columnExpr struct {
+	header string
+	index  int
+}
+
+func (e *columnExpr) eval(record []string) (string, error) {
+	if e.index >= len(record) {
+		return "", nil
+	}
+	return record[e.index], nil
+}
+
+type negateExpr struct {
+	x expr
+}
+
+func (e *negateExpr) eval(record []string) (string, error) {
+	s, err := e.x.eval(record)
+	if err != nil {
+		return "", err
+	}
+	n, err := toNumber(s)
+	if err != nil {
+		return "", err
+	}
+	return formatNumber(-n), nil
+}
+
+type binaryExpr struct {
+	op   string
+	x, y expr
+}
+
+func (e *binaryExpr) eval(record []string) (string, error) {
+	s, err := e.x.eval(record)
+	if err != nil {
+		return "", err
+	}
+	t, err := e.y.eval(record)
+	if err != nil {
+		return "", err
+	}
+
+	// + adds numbers, and concatenates values if either of them is not
+	// a number, so "1" + "2" is 3. concat always concatenates values.
+	m, errM := toNumber(s)
+	n, errN := toNumber(t)
+	if e.op == "+" && (errM != nil || errN != nil) {
+		return s + t, nil
+	}
+	if errM != nil {
+		return "", errM
+	}
+	if errN != nil {
+		return "", errN
+	}
+
+	switch e.op {
+	case "+":
+		return formatNumber(m + n), nil
+	case "-":
+		return formatNumber(m - n), nil
+	case "*":
+		return formatNumber(m * n), nil
+	case "/":
+		if n == 0 {
+			return "", fmt.Errorf("division by zero")
+		}
+		return formatNumber(m / n), nil
+	default:
+		if n == 0 {
+			return "", fmt.Errorf("division by zero")
+		}
+		return formatNumber(math.Mod(m, n)), nil
+	}
+}
+
+type exprFunc struct {
+	minArgs int
+	maxArgs int
+	call    func(args []string) (string, error)
+}
+
+func numberArgs(args []string) ([]float64, error) {
+	a := make([]float64, len(args))
+	for i, arg := range args {
+		n, err := toNumber(arg)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = n
+	}
+	return a, nil
+}
+
+var exprFuncs = map[string]exprFunc{
+	"upper": {1, 1, func(args []string) (string, error) {
+		return strings.ToUpper(args[0]), nil
+	}},
+	"lower": {1, 1, func(args []string) (string, error) {
+		return strings.ToLower(args[0]), nil
+	}},
+	"trim": {1, 1, func(args []string) (string, error) {
+		return strings.TrimSpace(args[0]), nil
+	}},
+	"len": {1, 1, func(args []string) (string, error) {
+		return strconv.Itoa(utf8.RuneCountInString(args[0])), nil
+	}},
+	"concat": {0, -1, func(args []string) (string, error) {
+		return strings.Join(args, ""), nil
+	}},
+	"abs": {1, 1, func(args []string) (string, error) {
+		a, err := numberArgs(args)
+		if err != nil {
+			return "", err
+		}
+		return formatNumber(math.Abs(a[0])), nil
+	}},
+	"round": {1, 2, func(args []string) (string, error) {
+		a, err := numberArgs(args)
+		if err != nil {
+			return "", err
+		}
+		decimals := 0
+		if len(a) == 2 {
+			decimals = int(a[1])
+		}
+		p := math.Pow(10, float64(decimals))
+		return formatNumber(math.Round(a[0]*p) / p), nil
+	}},
+	"min": {1, -1, func(args []string) (string, error) {
+		a, err := numberArgs(args)
+		if err != nil {
+			return "", err
+		}
+		n := a[0]
+		for _, x := range a[1:] {
+			n = math.Min(n, x)
+		}
+		return formatNumber(n), nil
+	}},
+	"max": {1, -1, func(args []string) (string, error) {
+		a, err := numberArgs(args)
+		if err != nil {
+			return "", err
+		}
+		n := a[0]
+		for _, x := range a[1:] {
+			n = math.Max(n, x)
+		}
+		return formatNumber(n), nil
+	}},
+}
+
+type callExpr struct {
+	name string
+	f    exprFunc
+	args []expr
+}
+
+func (e *callExpr) eval(record []string) (string, error) {
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		var err error
+		if args[i], err = arg.eval(record); err != nil {
+			return "", err
+		}
+	}
+	s, err := e.f.call(args)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", e.name, err)
+	}
+	return s, nil
+}
+
+type exprParser struct {
+	lex     *lexer
+	tok     token
+	columns []*columnExpr
+}
+
+func newExprParser(s string) (*exprParser, error) {
+	p := &exprParser{lex: &lexer{s: s}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *exprParser) next() error {
+	t, err := p.lex.scan()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *exprParser) isOperator(ops ...string) bool {
+	if p.tok.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.value == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.isOperator(op) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *exprParser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("%d: unexpected %q", p.tok.pos+1, p.tok.value)
+}
+
+func (p *exprParser) parseExpr() (expr, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseTerm() (expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/", "%") {
+		op := p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if p.isOperator("-") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	t := p.tok
+	switch {
+	case t.kind == tokenNumber:
+		if _, err := toNumber(t.value); err != nil {
+			return nil, err
+		}
+		return &literalExpr{value: t.value}, p.next()
+	case t.kind == tokenString:
+		return &literalExpr{value: t.value}, p.next()
+	case t.kind == tokenColumn:
+		c := &columnExpr{header: t.value}
+		p.columns = append(p.columns, c)
+		return c, p.next()
+	case t.kind == tokenIdent:
+		f, ok := exprFuncs[t.value]
+		if !ok {
+			return nil, fmt.Errorf("%q: unknown function", t.value)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		e := &callExpr{name: t.value, f: f}
+		for !p.isOperator(")") {
+			if len(e.args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			e.args = append(e.args, arg)
+		}
+		if len(e.args) < f.minArgs || (f.maxArgs >= 0 && len(e.args) > f.maxArgs) {
+			return nil, fmt.Errorf("%s: wrong number of arguments", t.value)
+		}
+		return e, p.next()
+	case p.isOperator("("):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+	return nil, p.unexpected()
+}
+
+type addition struct {
+	header  string
+	x       expr
+	columns []*columnExpr
+}
+
+// Adder appends columns computed from expressions to each record.
+type Adder struct {
+	additions []*addition
+}
+
+// NewAdder returns an Adder which appends columns in list.
+// The list is assignments like "HEADER=EXPR" separated by ",".
+func NewAdder(list string) (*Adder, error) {
+	a := &Adder{}
+	for list != "" {
+		i := strings.IndexByte(list, '=')
+		if i == -1 {
+			return nil, fmt.Errorf("%q: invalid addition", list)
+		}
+		header, rest := strings.TrimSpace(list[:i]), list[i+1:]
+
+		p, err := newExprParser(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", header, err)
+		}
+		x, err := p.parseExpr()
+		if err == nil && !p.isOperator(",") && p.tok.kind != tokenEOF {
+			err = p.unexpected()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", header, err)
+		}
+		a.additions = append(a.additions, &addition{
+			header:  header,
+			x:       x,
+			columns: p.columns,
+		})
+
+		list = ""
+		if p.isOperator(",") {
+			list = rest[p.tok.pos+1:]
+		}
+	}
+	return a, nil
+}
+
+func (a *Adder) ParseHeaders(headers []string) ([]string, error) {
+	headers = append([]string{}, headers...)
+	for _, addition := range a.additions {
+		for _, c := range addition.columns {
+			c.index = headerIndex(headers, c.header)
+			if c.index == -1 {
+				return nil, fmt.Errorf("%q: no such header", c.header)
+			}
+		}
+		headers = append(headers, addition.header)
+	}
+	return headers, nil
+}
+
+func (a *Adder) Transform(record []string) ([]string, error) {
+	for _, addition := range a.additions {
+		s, err := addition.x.eval(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", addition.header, err)
+		}
+		record = append(record, s)
+	}
+	return record, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var adderHeaders = []string{"id", "name", "price", "quantity", "unit price"}
+
+var adderTests = []struct {
+	list    string
+	headers []string
+	src     []string
+	dst     []string
+}{
+	{
+		list:    "total=$price*$quantity",
+		headers: []string{"total"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"1200"},
+	},
+	{
+		list:    `label=$name + "-" + $id`,
+		headers: []string{"label"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"Apple-7"},
+	},
+	{
+		list:    "a=1 + 2 * 3, b=(1 + 2) * 3, c=-$price % 7, d=10 / 4",
+		headers: []string{"a", "b", "c", "d"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"7", "9", "-4", "2.5"},
+	},
+	{
+		list:    "total=$price*$quantity, half=$total/2",
+		headers: []string{"total", "half"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"1200", "600"},
+	},
+	{
+		list:    "x=${unit price} * 2",
+		headers: []string{"x"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"6"},
+	},
+	{
+		list:    `u=upper($name), l=len($name), c=concat($id, ":", lower($name)), r=round($price / 7, 2), m=max($price, $quantity, 100), n=min(abs(-3), 5), t=trim("  x ")`,
+		headers: []string{"u", "l", "c", "r", "m", "n", "t"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"APPLE", "5", "7:apple", "8.57", "100", "3", "x"},
+	},
+	{
+		list:    `a=$id + $quantity, b=$id + $name, c="1" + "2", d=concat($id, $quantity), e=$id + ""`,
+		headers: []string{"a", "b", "c", "d", "e"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"27", "7Apple", "3", "720", "7"},
+	},
+	{
+		list:    `a=round(2.5), b=round(-2.5), c=round(-$price / 7, 2), d=round(-1.25, 1)`,
+		headers: []string{"a", "b", "c", "d"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{"3", "-3", "-8.57", "-1.3"},
+	},
+	{
+		list:    `s="a\"b,c"`,
+		headers: []string{"s"},
+		src:     []string{"7", "Apple", "60", "20", "3"},
+		dst:     []string{`a"b,c`},
+	},
+}
+
+func TestAdder(t *testing.T) {
+	for _, test := range adderTests {
+		a, err := NewAdder(test.list)
+		if err != nil {
+			t.Errorf("NewAdder(%q) returns %q, want nil", test.list, err)
+			continue
+		}
+
+		expectHeaders := append(append([]string{}, adderHeaders...), test.headers...)
+		actualHeaders, err := a.ParseHeaders(adderHeaders)
+		if err != nil {
+			t.Errorf("NewAdder(%q).ParseHeaders(%q) returns %q, want nil",
+				test.list, adderHeaders, err)
+			continue
+		}
+		if !reflect.DeepEqual(actualHeaders, expectHeaders) {
+			t.Errorf("NewAdder(%q).ParseHeaders(%q) = %q, want %q",
+				test.list, adderHeaders, actualHeaders, expectHeaders)
+		}
+
+		expect := append(append([]string{}, test.src...), test.dst...)
+		actual, err := a.Transform(append([]string{}, test.src...))
+		if err != nil {
+			t.Errorf("NewAdder(%q).Transform(%q) returns %q, want nil",
+				test.list, test.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("NewAdder(%q).Transform(%q) = %q, want %q",
+				test.list, test.src, actual, expect)
+		}
+	}
+}
+
+var adderErrorTests = []struct {
+	list string
+	src  []string
+}{
+	{list: "total"},
+	{list: "total="},
+	{list: "total=$price *"},
+	{list: "total=$price $quantity"},
+	{list: "total=foo($price)"},
+	{list: "total=upper($price, 1)"},
+	{list: `total="abc`},
+	{list: "total=$price # 2"},
+	{list: "total=$date"},
+	{list: "total=$price * $name", src: []string{"7", "Apple", "60", "20", "3"}},
+	{list: "total=$price / 0", src: []string{"7", "Apple", "60", "20", "3"}},
+}
+
+func TestAdderError(t *testing.T) {
+	for _, test := range adderErrorTests {
+		a, err := NewAdder(test.list)
+		if err == nil {
+			_, err = a.ParseHeaders(adderHeaders)
+		}
+		if err == nil && test.src != nil {
+			_, err = a.Transform(test.src)
+		}
+		if err == nil {
+			t.Errorf("NewAdder(%q) returns nil, want err", test.list)
+		}
+	}
+}
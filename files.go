@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+// readResult is the result of a read by contextReader.
+type readResult struct {
+	b   []byte
+	err error
+}
+
+// contextReader is a reader whose Read returns ctx.Err() when ctx is done,
+// even if a read of the underlying reader is blocked waiting for input.
+// The underlying reader is read in another goroutine,
+// which is left blocked until the read returns.
+type contextReader struct {
+	ctx     context.Context
+	results chan readResult
+	b       []byte
+	err     error
+}
+
+func newContextReader(ctx context.Context, r io.Reader) *contextReader {
+	cr := &contextReader{
+		ctx:     ctx,
+		results: make(chan readResult),
+	}
+	go cr.readAhead(r)
+	return cr
+}
+
+func (cr *contextReader) readAhead(r io.Reader) {
+	for {
+		b := make([]byte, 64<<10)
+		n, err := r.Read(b)
+		select {
+		case cr.results <- readResult{b: b[:n], err: err}:
+		case <-cr.ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	for len(cr.b) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		select {
+		case r := <-cr.results:
+			cr.b, cr.err = r.b, r.err
+		case <-cr.ctx.Done():
+			return 0, cr.ctx.Err()
+		}
+	}
+	n := copy(p, cr.b)
+	cr.b = cr.b[n:]
+	return n, nil
+}
+
+// Close does not close the underlying reader, which is standard input.
+func (cr *contextReader) Close() error {
+	return nil
+}
+
+var (
+	stdinOnce sync.Once
+	stdin     io.ReadCloser
+)
+
+// openStdin returns standard input, which stops a blocked read when ctx
+// is done, so that a signal is handled while waiting for input.
+// A regular file is returned as is, because reading it does not block
+// and the scanner splits it by ranges in --jobs.
+func openStdin(ctx context.Context) io.ReadCloser {
+	stdinOnce.Do(func() {
+		stdin = os.Stdin
+		if fi, err := os.Stdin.Stat(); err != nil || !fi.Mode().IsRegular() {
+			stdin = newContextReader(ctx, os.Stdin)
+		}
+	})
+	return stdin
+}
+
+// openFile opens the file at path, or returns standard input if path is empty.
+func openFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path == "" {
+		return openStdin(ctx), nil
+	}
+	return os.Open(path)
+}
+
+// checkFiles returns an error if one of the files at paths does not exist.
+// It does not open the files, since a FIFO can be read only once.
+func checkFiles(paths []string) error {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeFile(f io.Closer) {
+	if f != os.Stdin {
+		f.Close()
+	}
+}
+
+// fileError is an error in reading the file at path.
+type fileError struct {
+	path string
+	err  error
+}
+
+func (e *fileError) Error() string {
+	if _, ok := e.err.(*os.PathError); ok || e.path == "" {
+		return e.err.Error()
+	}
+	return e.path + ": " + e.err.Error()
+}
+
+// scanFile writes the headers and the records of the file at path to w
+// until ctx is done.
+func scanFile(ctx context.Context, c *csvp.CSVScanner, w Writer, path string) error {
+	f, err := openFile(ctx, path)
+	if err != nil {
+		return &fileError{path: path, err: err}
+	}
+	defer closeFile(f)
+
+	c.InitializeReader(f)
+	parsedHeaders := false
+	for c.ScanContext(ctx) {
+		if !parsedHeaders {
+			if err := w.WriteHeaders(c.Headers()); err != nil {
+				return err
+			}
+			parsedHeaders = true
+			if !c.Selector().DropHeaders() {
+				continue
+			}
+		}
+		if err := w.Write(c.Record()); err != nil {
+			return err
+		}
+	}
+	if err := c.Err(); err != nil {
+		if err == ctx.Err() {
+			return err
+		}
+		return &fileError{path: path, err: err}
+	}
+	return nil
+}
+
+// scanResult is headers, a record, or an error scanned from a file.
+type scanResult struct {
+	isHeaders bool
+	record    []string
+	err       error
+}
+
+// chanWriter sends headers and records to ch until done is closed.
+type chanWriter struct {
+	ch   chan<- scanResult
+	done <-chan struct{}
+}
+
+func (c *chanWriter) send(r scanResult) error {
+	select {
+	case c.ch <- r:
+		return nil
+	case <-c.done:
+		return errStop
+	}
+}
+
+func (c *chanWriter) WriteHeaders(headers []string) error {
+	return c.send(scanResult{isHeaders: true, record: headers})
+}
+
+func (c *chanWriter) Write(record []string) error {
+	return c.send(scanResult{record: record})
+}
+
+func (c *chanWriter) Flush() error {
+	return nil
+}
+
+func (c *chanWriter) Close() error {
+	return nil
+}
+
+// scanFiles scans the files at paths in len(scanners) goroutines,
+// and writes the headers and the records to w in the order of paths.
+// Each goroutine opens one file at a time.
+// An error of a file is passed to skip, and ignored if skip returns nil.
+func scanFiles(ctx context.Context, scanners []*csvp.CSVScanner, w Writer, paths []string, skip func(err error) error) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	results := make([]chan scanResult, len(paths))
+	for i := range results {
+		results[i] = make(chan scanResult, 64)
+	}
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range paths {
+			select {
+			case indexes <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for _, c := range scanners {
+		go func(c *csvp.CSVScanner) {
+			for i := range indexes {
+				cw := &chanWriter{ch: results[i], done: done}
+				if err := scanFile(ctx, c, cw, paths[i]); err != nil && err != errStop {
+					cw.send(scanResult{err: err})
+				}
+				close(results[i])
+			}
+		}(c)
+	}
+
+	for _, ch := range results {
+		for r := range ch {
+			var err error
+			switch {
+			case r.err != nil:
+				err = skip(r.err)
+			case r.isHeaders:
+				err = w.WriteHeaders(r.record)
+			default:
+				err = w.Write(r.record)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+func writeFiles(t *testing.T, dir string, n int) []string {
+	var paths []string
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%02d.csv", i))
+		src := "name,value\n"
+		for j := 0; j < i*10; j++ {
+			src += fmt.Sprintf("f%d,%d\n", i, j)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func skipNone(err error) error {
+	return err
+}
+
+func TestScanFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeFiles(t, dir, 20)
+
+	expect := &DummyWriter{}
+	c := csvp.NewCSVScanner(csvp.NewHeaders("value"), nil)
+	for _, path := range paths {
+		if err := scanFile(context.Background(), c, expect, path); err != nil {
+			t.Fatalf("scanFile(%q) returns %q, want nil", path, err)
+		}
+	}
+
+	for _, n := range []int{1, 3, 30} {
+		var scanners []*csvp.CSVScanner
+		for i := 0; i < n; i++ {
+			scanners = append(scanners, csvp.NewCSVScanner(csvp.NewHeaders("value"), nil))
+		}
+		actual := &DummyWriter{}
+		if err := scanFiles(context.Background(), scanners, actual, paths, skipNone); err != nil {
+			t.Errorf("scanFiles() with %d scanners returns %q, want nil", n, err)
+		}
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("scanFiles() with %d scanners:\ngot:\n%q\nwant:\n%q",
+				n, actual.records, expect.records)
+		}
+	}
+}
+
+func TestScanFilesError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeFiles(t, dir, 3)
+	paths = append(paths[:2], filepath.Join(dir, "missing.csv"), paths[2])
+
+	scanners := []*csvp.CSVScanner{
+		csvp.NewCSVScanner(csvp.NewAll(), nil),
+		csvp.NewCSVScanner(csvp.NewAll(), nil),
+	}
+	w := &DummyWriter{}
+	if err := scanFiles(context.Background(), scanners, w, paths, skipNone); err == nil {
+		t.Errorf("scanFiles() with a missing file returns nil, want error")
+	}
+	if len(w.records) != 10 {
+		t.Errorf("scanFiles() writes %d records before the error, want 10",
+			len(w.records))
+	}
+}
+
+func TestScanFilesSkip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeFiles(t, dir, 3)
+	malformed := filepath.Join(dir, "malformed.csv")
+	if err := ioutil.WriteFile(malformed, []byte("name,value\n\"a,1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths[:2], filepath.Join(dir, "missing.csv"), malformed, paths[2])
+
+	var skipped []error
+	skip := func(err error) error {
+		skipped = append(skipped, err)
+		return nil
+	}
+	scanners := []*csvp.CSVScanner{
+		csvp.NewCSVScanner(csvp.NewAll(), nil),
+		csvp.NewCSVScanner(csvp.NewAll(), nil),
+	}
+	w := &DummyWriter{}
+	if err := scanFiles(context.Background(), scanners, w, paths, skip); err != nil {
+		t.Errorf("scanFiles() returns %q, want nil", err)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("scanFiles() skips %q, want 2 errors", skipped)
+	}
+	if len(w.records) != 30 {
+		t.Errorf("scanFiles() writes %d records, want 30", len(w.records))
+	}
+	for _, err := range skipped {
+		if _, ok := err.(*fileError); !ok {
+			t.Errorf("scanFiles() skips %T, want *fileError", err)
+		}
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeFiles(t, dir, 2)
+
+	if err := checkFiles(paths); err != nil {
+		t.Errorf("checkFiles(%q) returns %q, want nil", paths, err)
+	}
+	paths = append(paths, filepath.Join(dir, "missing.csv"))
+	if err := checkFiles(paths); err == nil {
+		t.Errorf("checkFiles(%q) returns nil, want error", paths)
+	}
+}
+
+func TestFileSkipper(t *testing.T) {
+	ferr := &fileError{path: "a.csv", err: errors.New("bad record")}
+	other := errors.New("other")
+
+	s := &fileSkipper{}
+	if err := s.skip(ferr); err != ferr {
+		t.Errorf("skip(%q) without keepGoing returns %v, want %q", ferr, err, ferr)
+	}
+
+	s = &fileSkipper{keepGoing: true}
+	if err := s.skip(ferr); err != nil {
+		t.Errorf("skip(%q) returns %q, want nil", ferr, err)
+	}
+	if err := s.skip(other); err != other {
+		t.Errorf("skip(%q) returns %v, want %q", other, err, other)
+	}
+	if s.failed != 1 {
+		t.Errorf("failed = %d, want 1", s.failed)
+	}
+}
+
+func TestContextReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := newContextReader(ctx, pr)
+
+	go pw.Write([]byte("a,b\n"))
+	b := make([]byte, 2)
+	for _, expect := range []string{"a,", "b\n"} {
+		n, err := r.Read(b)
+		if err != nil || string(b[:n]) != expect {
+			t.Errorf("Read() = %q, %v, want %q, nil", b[:n], err, expect)
+		}
+	}
+
+	read := make(chan error)
+	go func() {
+		_, err := r.Read(b)
+		read <- err
+	}()
+	cancel()
+	if err := <-read; err != context.Canceled {
+		t.Errorf("blocked Read() returns %v after cancel, want %q", err, context.Canceled)
+	}
+}
+
+func TestContextReaderEOF(t *testing.T) {
+	r := newContextReader(context.Background(), strings.NewReader("a,b\n"))
+	b, err := ioutil.ReadAll(r)
+	if err != nil || string(b) != "a,b\n" {
+		t.Errorf("ReadAll() = %q, %v, want %q, nil", b, err, "a,b\n")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+type frequencyEntry struct {
+	key   []string
+	count int
+}
+
+// Frequency counts records for each distinct value of headers.
+type Frequency struct {
+	w             Writer
+	by            *csvp.Headers
+	topN          int
+	total         int
+	counts        map[string]*frequencyEntry
+	entries       []*frequencyEntry
+	parsedHeaders bool
+}
+
+// NewFrequency returns a Frequency which counts values of headers in list,
+// and prints only topN most frequent values unless topN is negative.
+func NewFrequency(w Writer, list string, topN int) *Frequency {
+	return &Frequency{
+		w:      w,
+		by:     csvp.NewHeaders(list),
+		topN:   topN,
+		counts: make(map[string]*frequencyEntry),
+	}
+}
+
+func (f *Frequency) WriteHeaders(headers []string) error {
+	if err := f.by.ParseHeaders(headers); err != nil {
+		return err
+	}
+	if err := checkHeaders(f.by); err != nil {
+		return err
+	}
+	if f.parsedHeaders {
+		return nil
+	}
+	f.parsedHeaders = true
+
+	outputHeaders := append([]string{}, f.by.OutputHeaders()...)
+	outputHeaders = append(outputHeaders, "count", "percent")
+	return f.w.WriteHeaders(outputHeaders)
+}
+
+func (f *Frequency) Write(record []string) error {
+	key, err := f.by.Select(record)
+	if err != nil {
+		return err
+	}
+
+	k := recordKey(key)
+	e, ok := f.counts[k]
+	if !ok {
+		e = &frequencyEntry{key: key}
+		f.counts[k] = e
+		f.entries = append(f.entries, e)
+	}
+	e.count++
+	f.total++
+	return nil
+}
+
+func (f *Frequency) Flush() error {
+	sort.SliceStable(f.entries, func(i, j int) bool {
+		return f.entries[i].count > f.entries[j].count
+	})
+	entries := f.entries
+	if f.topN >= 0 && f.topN < len(entries) {
+		entries = entries[:f.topN]
+	}
+
+	for _, e := range entries {
+		percent := 100 * float64(e.count) / float64(f.total)
+		record := append([]string{}, e.key...)
+		record = append(record,
+			strconv.Itoa(e.count),
+			strconv.FormatFloat(percent, 'f', 2, 64))
+		if err := f.w.Write(record); err != nil {
+			if err == errStop {
+				break
+			}
+			return err
+		}
+	}
+	f.entries = nil
+	return f.w.Flush()
+}
+
+func (f *Frequency) Close() error {
+	return f.w.Close()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var frequencyHeaders = []string{"id", "status", "note"}
+
+var frequencyItems = [][]string{
+	{"1", "done", "a\nb"},
+	{"2", "todo", ""},
+	{"3", "done", "a\nb"},
+	{"4", "doing", ""},
+	{"5", "done", "c"},
+	{"6", "todo", ""},
+	{"7", "todo", ""},
+	{"8", "done", ""},
+}
+
+var frequencyTests = []struct {
+	list    string
+	topN    int
+	headers []string
+	dst     [][]string
+}{
+	{
+		list:    "status",
+		topN:    -1,
+		headers: []string{"status", "count", "percent"},
+		dst: [][]string{
+			{"done", "4", "50.00"},
+			{"todo", "3", "37.50"},
+			{"doing", "1", "12.50"},
+		},
+	},
+	{
+		list:    "status",
+		topN:    1,
+		headers: []string{"status", "count", "percent"},
+		dst: [][]string{
+			{"done", "4", "50.00"},
+		},
+	},
+	{
+		list:    "note",
+		topN:    -1,
+		headers: []string{"note", "count", "percent"},
+		dst: [][]string{
+			{"", "5", "62.50"},
+			{"a\nb", "2", "25.00"},
+			{"c", "1", "12.50"},
+		},
+	},
+	{
+		list:    "status,note",
+		topN:    2,
+		headers: []string{"status", "note", "count", "percent"},
+		dst: [][]string{
+			{"todo", "", "3", "37.50"},
+			{"done", "a\nb", "2", "25.00"},
+		},
+	},
+}
+
+func TestFrequency(t *testing.T) {
+	for _, test := range frequencyTests {
+		w := &DummyWriter{}
+		f := NewFrequency(w, test.list, test.topN)
+		self := fmt.Sprintf("{list=%q, topN=%d}", test.list, test.topN)
+
+		if err := f.WriteHeaders(frequencyHeaders); err != nil {
+			t.Errorf("%s.WriteHeaders(%q) returns %q, want nil",
+				self, frequencyHeaders, err)
+			continue
+		}
+		for _, record := range frequencyItems {
+			if err := f.Write(record); err != nil {
+				t.Errorf("%s.Write(%q) returns %q, want nil",
+					self, record, err)
+			}
+		}
+		if err := f.Flush(); err != nil {
+			t.Errorf("%s.Flush() returns %q, want nil", self, err)
+		}
+
+		if !reflect.DeepEqual(w.headers, [][]string{test.headers}) {
+			t.Errorf("%s: headers = %q, want %q",
+				self, w.headers, [][]string{test.headers})
+		}
+		expect := test.dst
+		actual := w.records
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s",
+				self, toLines(actual), toLines(expect))
+		}
+	}
+}
+
+func TestFrequencyNoSuchHeader(t *testing.T) {
+	f := NewFrequency(&DummyWriter{}, "date", -1)
+	if err := f.WriteHeaders(frequencyHeaders); err == nil {
+		t.Errorf("WriteHeaders(%q) returns nil, want err", frequencyHeaders)
+	}
+}
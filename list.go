@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+// splitList splits s at each sep which is not escaped by a backslash.
+// Backslashes are kept so that each part can be split again.
+func splitList(s string, sep rune) []string {
+	if s == "" {
+		return []string{}
+	}
+
+	a := make([]string, 0)
+	start, escaped := 0, false
+	for i, ch := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == sep:
+			a = append(a, s[start:i])
+			start = i + utf8.RuneLen(ch)
+		}
+	}
+	return append(a, s[start:])
+}
+
+// headerIndex returns the index of the first header in headers,
+// or -1 if header is not present.
+func headerIndex(headers []string, header string) int {
+	for i, h := range headers {
+		if h == header {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkHeaders returns an error if h has headers not found in ParseHeaders.
+func checkHeaders(h *csvp.Headers) error {
+	if missing := h.Missing(); len(missing) > 0 {
+		return fmt.Errorf("%q: no such header", missing[0])
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var splitListTests = []struct {
+	list string
+	sep  rune
+	dst  []string
+}{
+	{list: "", sep: ',', dst: []string{}},
+	{list: "a", sep: ',', dst: []string{"a"}},
+	{list: "a,b,,c", sep: ',', dst: []string{"a", "b", "", "c"}},
+	{list: `a\,b,c`, sep: ',', dst: []string{`a\,b`, "c"}},
+	{list: `a\\,b`, sep: ',', dst: []string{`a\\`, "b"}},
+	{list: "price:num:desc", sep: ':', dst: []string{"price", "num", "desc"}},
+	{list: "a→b→c", sep: '→', dst: []string{"a", "b", "c"}},
+}
+
+func TestSplitList(t *testing.T) {
+	for _, test := range splitListTests {
+		expect := test.dst
+		actual := splitList(test.list, test.sep)
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("splitList(%q, %q) = %q, want %q",
+				test.list, test.sep, actual, expect)
+		}
+	}
+}
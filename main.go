@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 
+	"github.com/nil-two/csvp/csvp"
 	"github.com/ogier/pflag"
 )
 
@@ -17,9 +21,44 @@ var (
 	flagset         = pflag.NewFlagSet(cmdName, pflag.ContinueOnError)
 	indexesList     = flagset.StringP("indexes", "i", "", "")
 	headersList     = flagset.StringP("headers", "h", "", "")
+	selectSpec      = flagset.String("select", "", "")
 	isTSV           = flagset.BoolP("tsv", "t", false, "")
 	delimiter       = flagset.StringP("delimiter", "d", ",", "")
 	outputDelimiter = flagset.StringP("output-delimiter", "D", "\t", "")
+	output          = flagset.StringP("output", "o", "", "")
+	sortBy          = flagset.StringP("sort-by", "", "", "")
+	groupBy         = flagset.StringP("group-by", "", "", "")
+	aggregations    = flagset.StringP("agg", "", "", "")
+	freqList        = flagset.StringP("freq", "", "", "")
+	freqTop         = flagset.IntP("freq-top", "", -1, "")
+	isStats         = flagset.BoolP("stats", "", false, "")
+	statsTop        = flagset.IntP("stats-top", "", 3, "")
+	isStatsApprox   = flagset.BoolP("stats-approx", "", false, "")
+	mapList         = flagset.StringP("map", "", "", "")
+	addList         = flagset.StringP("add", "", "", "")
+	splittings      = flagset.StringP("split", "", "", "")
+	mergings        = flagset.StringP("merge", "", "", "")
+	rowsList        = flagset.StringP("rows", "", "", "")
+	head            = flagset.IntP("head", "", -1, "")
+	tail            = flagset.IntP("tail", "", -1, "")
+	isUnique        = flagset.BoolP("unique", "", false, "")
+	uniqueBy        = flagset.StringP("unique-by", "", "", "")
+	isKeepLast      = flagset.BoolP("unique-keep-last", "", false, "")
+	isCountUnique   = flagset.BoolP("count-unique", "", false, "")
+	isUniqueHash    = flagset.BoolP("unique-hash", "", false, "")
+	isTranspose     = flagset.BoolP("transpose", "", false, "")
+	partitionBy     = flagset.StringP("partition-by", "", "", "")
+	partitionTmpl   = flagset.StringP("partition-template", "", "", "")
+	outputDir       = flagset.StringP("output-dir", "", ".", "")
+	maxOpenFiles    = flagset.IntP("max-open-files", "", defaultMaxOpenFiles, "")
+	chunkRows       = flagset.IntP("chunk-rows", "", 0, "")
+	chunkBytes      = flagset.IntP("chunk-bytes", "", 0, "")
+	outputPrefix    = flagset.StringP("output-prefix", "", "part-", "")
+	isKeepGoing     = flagset.BoolP("keep-going", "", false, "")
+	jobs            = flagset.IntP("jobs", "j", 1, "")
+	parallelFiles   = flagset.IntP("parallel-files", "", 1, "")
+	isListHeaders   = flagset.BoolP("list-headers", "", false, "")
+	isListSample    = flagset.BoolP("list-headers-sample", "", false, "")
 	isHelp          = flagset.BoolP("help", "", false, "")
 	isVersion       = flagset.BoolP("version", "", false, "")
 )
@@ -34,17 +73,87 @@ Options:
                  select only these indexes
   -h, --headers=LIST
                  select only these headers
+  --select=SCHEME:EXPR
+                 select columns by EXPR of SCHEME (%s)
   -t, --tsv
                  equivalent to -d'\t'
   -d, --delimiter=DELIM
                  use DELIM instead of comma for field delimiter
   -D, --output-delimiter=STRING
                  use STRING as the output delimiter (default: \t)
+  -o, --output=FILE
+                 write to FILE instead of standard output
+  --map=LIST
+                 transform values of headers by functions
+  --add=LIST
+                 append columns computed from expressions
+  --split=LIST
+                 split columns into columns by separators
+  --merge=LIST
+                 merge columns into a column with joiners
+  --rows=LIST
+                 select only these records
+  --head=N
+                 select only the first N records
+  --tail=N
+                 select only the last N records
+  --unique
+                 drop records which appeared before
+  --unique-by=LIST
+                 drop records whose values of these headers appeared before
+  --unique-keep-last
+                 keep the last record instead of the first in --unique
+  --count-unique
+                 print the number of records in front of each unique record
+  --unique-hash
+                 remember only hashes of records in --unique to save memory
+  --sort-by=LIST
+                 sort records by these headers
+  --group-by=LIST
+                 group records by these headers
+  --agg=LIST
+                 print these aggregations for each group
+  --freq=LIST
+                 print the number of records for each value of these headers
+  --freq-top=N
+                 print only N most frequent values in --freq
+  --stats
+                 print statistics of each column instead of records
+  --stats-top=N
+                 print N most frequent values in --stats (default: 3)
+  --stats-approx
+                 approximate distinct counts in --stats to save memory
+  --transpose
+                 print columns as records and records as columns
+  --partition-by=LIST
+                 write records into files for each value of these headers
+  --partition-template=TEMPLATE
+                 name files in --partition-by like '{customer}/{date}.csv'
+  --output-dir=DIR
+                 write files in --partition-by under DIR (default: .)
+  --max-open-files=N
+                 keep at most N files open in --partition-by (default: 64)
+  --chunk-rows=N
+                 write every N records into a new file
+  --chunk-bytes=N
+                 write records into a new file before it exceeds N bytes
+  --output-prefix=PREFIX
+                 name files in --chunk-rows like PREFIX0001 (default: part-)
+  --keep-going
+                 report unreadable or malformed FILEs and process the rest
+  -j, --jobs=N
+                 parse records in N goroutines (default: 1)
+  --parallel-files=N
+                 parse N files at once and print them in order (default: 1)
+  --list-headers
+                 print indexes and headers of each FILE
+  --list-headers-sample
+                 print a sample value of each header in --list-headers
   --help
                  display this help text and exit
   --version
                  output version information and exit
-`[1:], cmdName)
+`[1:], cmdName, strings.Join(csvp.Schemes(), ", "))
 }
 
 func printVersion() {
@@ -72,19 +181,257 @@ func toDelimiter(s string) (ch rune, err error) {
 	return a[0], nil
 }
 
-func do(c *CSVScanner, rs []io.Reader) error {
-	for _, r := range rs {
-		c.InitializeReader(r)
+// checkFlags returns an error if the flags are invalid.
+func checkFlags() error {
+	switch {
+	case *jobs < 1:
+		return fmt.Errorf("%d: invalid number of jobs", *jobs)
+	case *parallelFiles < 1:
+		return fmt.Errorf("%d: invalid number of files", *parallelFiles)
+	case *statsTop < 0:
+		return fmt.Errorf("%d: invalid number of values in --stats-top", *statsTop)
+	case *isListHeaders && (*indexesList != "" || *headersList != "" || *selectSpec != ""):
+		return errors.New("--list-headers cannot be used with a list")
+	case (*partitionBy != "" || *partitionTmpl != "") && (*chunkRows > 0 || *chunkBytes > 0):
+		return errors.New("--partition-by and --chunk-rows cannot be used together")
+	case *output != "" && (*partitionBy != "" || *partitionTmpl != "" || *chunkRows > 0 || *chunkBytes > 0):
+		return errors.New("--output cannot be used with --partition-by or --chunk-rows")
+	case *isTranspose && (*partitionBy != "" || *partitionTmpl != "" || *chunkRows > 0 || *chunkBytes > 0):
+		return errors.New("--transpose cannot be used with --partition-by or --chunk-rows")
+	}
+	return nil
+}
+
+// fileSkipper skips errors in reading files if keepGoing is true,
+// and counts the skipped files.
+type fileSkipper struct {
+	keepGoing bool
+	failed    int
+}
+
+// skip reports err and returns nil,
+// if err is an error in reading a file and s keeps going.
+func (s *fileSkipper) skip(err error) error {
+	if _, ok := err.(*fileError); ok && s.keepGoing {
+		printErr(err)
+		s.failed++
+		return nil
+	}
+	return err
+}
+
+func do(ctx context.Context, scanners []*csvp.CSVScanner, w Writer, paths []string, skip func(error) error) error {
+	var err error
+	if len(scanners) == 1 {
+		for _, path := range paths {
+			err = scanFile(ctx, scanners[0], w, path)
+			if err = skip(err); err != nil {
+				break
+			}
+		}
+	} else {
+		err = scanFiles(ctx, scanners, w, paths, skip)
+	}
+	if err != nil && err != errStop {
+		return err
+	}
+	return w.Flush()
+}
 
-		for c.Scan() {
-			fmt.Println(c.Text())
+func listHeaders(ctx context.Context, c *csvp.CSVScanner, w Writer, paths []string, skip func(error) error) error {
+	for _, path := range paths {
+		f, err := openFile(ctx, path)
+		if err != nil {
+			if err = skip(&fileError{path: path, err: err}); err != nil {
+				return err
+			}
+			continue
 		}
+		c.InitializeReader(f)
 
+		var headers, sample []string
+		if c.ScanContext(ctx) {
+			headers = c.Record()
+		}
+		if *isListSample && c.ScanContext(ctx) {
+			sample = c.Record()
+		}
+		closeFile(f)
 		if err := c.Err(); err != nil {
-			return err
+			if err == ctx.Err() {
+				return err
+			}
+			if err = skip(&fileError{path: path, err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for j, header := range headers {
+			record := []string{strconv.Itoa(j + 1), header}
+			if *isListSample {
+				var value string
+				if j < len(sample) {
+					value = sample[j]
+				}
+				record = append(record, value)
+			}
+			if len(paths) > 1 {
+				record = append([]string{path}, record...)
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
 		}
 	}
-	return nil
+	return w.Flush()
+}
+
+// outputFiles is a Writer which writes records into files
+// instead of the output.
+type outputFiles interface {
+	Writer
+	// Abort closes and removes the files written so far.
+	Abort() error
+}
+
+// newWriter returns the Writer of records to out,
+// and its outputFiles if records are written into files.
+func newWriter(selector csvp.Selector, out io.Writer) (Writer, outputFiles, error) {
+	// The header rows of aggregations, frequencies and statistics are not
+	// the selected headers, so they are printed even if the selector drops
+	// the headers.
+	printHeaders := !selector.DropHeaders() ||
+		*isStats || *freqList != "" || *groupBy != "" || *aggregations != ""
+
+	var w Writer = NewPrinter(out, *outputDelimiter, printHeaders)
+	var files outputFiles
+	if *partitionBy != "" || *partitionTmpl != "" {
+		p, err := NewPartitioner(*outputDir, *partitionBy, *partitionTmpl, *outputDelimiter)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.SetMaxOpenFiles(*maxOpenFiles)
+		files = p
+	}
+	if *chunkRows > 0 || *chunkBytes > 0 {
+		files = NewChunker(*outputPrefix, *chunkRows, *chunkBytes, *outputDelimiter)
+	}
+	if files != nil {
+		w = files
+	}
+	if *isTranspose {
+		w = NewTransposer(w, printHeaders)
+	}
+	// The rows are selected from the sorted, aggregated or unique records.
+	if *rowsList != "" || *head >= 0 || *tail >= 0 {
+		r, err := NewRows(w, *rowsList, *head, *tail)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = r
+	}
+	if *sortBy != "" {
+		s, err := NewSorter(w, *sortBy)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = s
+	}
+	if *isStats {
+		w = NewStats(w, *statsTop, *isStatsApprox)
+	}
+	if *freqList != "" {
+		w = NewFrequency(w, *freqList, *freqTop)
+	}
+	if *groupBy != "" || *aggregations != "" {
+		a, err := NewAggregator(w, *groupBy, *aggregations)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = a
+	}
+	if *isUnique || *uniqueBy != "" || *isCountUnique || *isKeepLast || *isUniqueHash {
+		u := NewUnique(w, *uniqueBy)
+		u.SetKeepLast(*isKeepLast)
+		u.SetCount(*isCountUnique)
+		u.SetHash(*isUniqueHash)
+		w = u
+	}
+	return w, files, nil
+}
+
+// selectorFlags are the flags which are shorthands for --select=SCHEME:LIST.
+var selectorFlags = []struct {
+	scheme string
+	list   *string
+}{
+	{"indexes", indexesList},
+	{"headers", headersList},
+}
+
+func newScanner() (*csvp.CSVScanner, error) {
+	spec := *selectSpec
+	for _, f := range selectorFlags {
+		if *f.list == "" {
+			continue
+		}
+		if spec != "" {
+			return nil, errors.New("only one type of list may be specified")
+		}
+		spec = f.scheme + ":" + *f.list
+	}
+	if spec == "" {
+		spec = "all:"
+	}
+	selector, err := csvp.NewSelector(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	c := csvp.NewCSVScanner(selector, nil)
+	if !*isListHeaders {
+		c.SetJobs(*jobs)
+	}
+	if *mapList != "" {
+		m, err := NewMapper(*mapList)
+		if err != nil {
+			return nil, err
+		}
+		c.AddTransform(m)
+	}
+	if *addList != "" {
+		a, err := NewAdder(*addList)
+		if err != nil {
+			return nil, err
+		}
+		c.AddTransform(a)
+	}
+	if *splittings != "" {
+		s, err := NewSplitter(*splittings)
+		if err != nil {
+			return nil, err
+		}
+		c.AddTransform(s)
+	}
+	if *mergings != "" {
+		m, err := NewMerger(*mergings)
+		if err != nil {
+			return nil, err
+		}
+		c.AddTransform(m)
+	}
+	switch {
+	case *isTSV:
+		c.SetDelimiter('\t')
+	default:
+		ch, err := toDelimiter(*delimiter)
+		if err != nil {
+			return nil, err
+		}
+		c.SetDelimiter(ch)
+	}
+	return c, nil
 }
 
 func _main() int {
@@ -103,55 +450,101 @@ func _main() int {
 		return 0
 	}
 
-	var selector Selector
-	switch {
-	case *indexesList != "" && *headersList != "":
-		printErr("only one type of list may be specified")
+	if err := checkFlags(); err != nil {
+		printErr(err)
 		guideToHelp()
 		return 2
-	case *indexesList != "":
-		selector = NewIndexes(*indexesList)
-	case *headersList != "":
-		selector = NewHeaders(*headersList)
-	default:
-		selector = NewAll()
 	}
 
-	c := NewCSVScanner(selector, nil)
-	c.SetOutputDelimiter(*outputDelimiter)
-	switch {
-	case *isTSV:
-		c.SetDelimiter('\t')
-	default:
-		ch, err := toDelimiter(*delimiter)
-		if err != nil {
+	c, err := newScanner()
+	if err != nil {
+		printErr(err)
+		guideToHelp()
+		return 2
+	}
+
+	paths := flagset.Args()
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	// The files are opened lazily, but a missing file is still reported
+	// before any output unless --keep-going skips it.
+	skipper := &fileSkipper{keepGoing: *isKeepGoing}
+	if !skipper.keepGoing {
+		if err := checkFiles(paths); err != nil {
 			printErr(err)
 			guideToHelp()
 			return 2
 		}
-		c.SetDelimiter(ch)
 	}
 
-	var rs []io.Reader
-	if flagset.NArg() == 0 {
-		rs = append(rs, os.Stdin)
-	} else {
-		for _, path := range flagset.Args() {
-			f, err := os.Open(path)
-			if err != nil {
-				printErr(err)
-				guideToHelp()
-				return 2
+	// On SIGINT or SIGTERM, the scan stops and the deferred Close calls
+	// flush the buffered output and remove the partial output file.
+	// The files of --partition-by and --chunk-rows are aborted.
+	ctx, stopSignals := signalContext(context.Background())
+	defer stopSignals()
+
+	var out io.Writer = os.Stdout
+	var outputFile *AtomicFile
+	if *output != "" {
+		f, err := CreateAtomicFile(*output)
+		if err != nil {
+			printErr(err)
+			return 1
+		}
+		defer f.Close()
+
+		out = f
+		outputFile = f
+	}
+
+	if *isListHeaders {
+		w := NewPrinter(out, *outputDelimiter, false)
+		defer w.Close()
+
+		if err := listHeaders(ctx, c, w, paths, skipper.skip); err != nil {
+			if err == ctx.Err() {
+				return signalStatus(stopSignals())
 			}
-			defer f.Close()
+			printErr(err)
+			return 1
+		}
+	} else {
+		w, files, err := newWriter(c.Selector(), out)
+		if err != nil {
+			printErr(err)
+			guideToHelp()
+			return 2
+		}
+		defer w.Close()
 
-			rs = append(rs, f)
+		scanners := []*csvp.CSVScanner{c}
+		for len(scanners) < *parallelFiles && len(scanners) < len(paths) {
+			// newScanner does not fail with the flags accepted above.
+			s, _ := newScanner()
+			scanners = append(scanners, s)
+		}
+		if err := do(ctx, scanners, w, paths, skipper.skip); err != nil {
+			if err == ctx.Err() {
+				if files != nil {
+					files.Abort()
+				}
+				return signalStatus(stopSignals())
+			}
+			printErr(err)
+			return 1
 		}
 	}
 
-	if err := do(c, rs); err != nil {
-		printErr(err)
-		return 1
+	if outputFile != nil {
+		if err := outputFile.Commit(); err != nil {
+			printErr(err)
+			return 1
+		}
+	}
+	if skipper.failed > 0 {
+		printErr(fmt.Sprintf("%d of %d files failed", skipper.failed, len(paths)))
+		return 3
 	}
 	return 0
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+// setFlags parses args, and returns a function which resets the flags
+// in args to their default values. args must be long flags.
+func setFlags(t *testing.T, args ...string) func() {
+	reset := func() {
+		for _, arg := range args {
+			name := strings.TrimPrefix(arg, "--")
+			if i := strings.Index(name, "="); i >= 0 {
+				name = name[:i]
+			}
+			if f := flagset.Lookup(name); f != nil {
+				f.Value.Set(f.DefValue)
+			}
+		}
+	}
+	if err := flagset.Parse(args); err != nil {
+		reset()
+		t.Fatalf("Parse(%q) returns %q, want nil", args, err)
+	}
+	return reset
+}
+
+func TestNewWriterSummaryHeaders(t *testing.T) {
+	tests := []struct {
+		args   []string
+		expect string
+	}{
+		{[]string{}, "x\nx\ny\n"},
+		{[]string{"--group-by=a"}, "a\nx\ny\n"},
+		{[]string{"--agg=count()"}, "count()\n3\n"},
+		{[]string{"--freq=a"}, "a\tcount\tpercent\nx\t2\t66.67\ny\t1\t33.33\n"},
+	}
+	for _, test := range tests {
+		func() {
+			defer setFlags(t, test.args...)()
+
+			b := &bytes.Buffer{}
+			w, _, err := newWriter(csvp.NewHeaders("a"), b)
+			if err != nil {
+				t.Fatalf("%q: newWriter returns %q, want nil", test.args, err)
+			}
+			w.WriteHeaders([]string{"a"})
+			for _, field := range []string{"x", "x", "y"} {
+				w.Write([]string{field})
+			}
+			if err := w.Flush(); err != nil {
+				t.Errorf("%q: Flush returns %q, want nil", test.args, err)
+			}
+			if actual := b.String(); actual != test.expect {
+				t.Errorf("%q: got %q, want %q", test.args, actual, test.expect)
+			}
+		}()
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	tests := []struct {
+		args   []string
+		src    []string
+		expect string
+	}{
+		{[]string{"--head=2"}, []string{"c", "a", "b", "a"}, "c\na\n"},
+		{[]string{"--sort-by=a", "--head=2"}, []string{"c", "a", "b", "a"}, "a\na\n"},
+		{[]string{"--sort-by=a", "--tail=1"}, []string{"c", "a", "b", "a"}, "c\n"},
+		{[]string{"--unique", "--head=2"}, []string{"a", "a", "b", "c"}, "a\nb\n"},
+		{[]string{"--unique", "--tail=2"}, []string{"a", "b", "c", "c"}, "b\nc\n"},
+		{[]string{"--unique-keep-last"}, []string{"a", "b", "a"}, "b\na\n"},
+		{[]string{"--unique-hash"}, []string{"a", "b", "a"}, "a\nb\n"},
+		{[]string{"--group-by=a", "--agg=count()", "--head=1"}, []string{"a", "a", "b"}, "a\tcount()\na\t2\n"},
+		{[]string{"--freq=a", "--rows=2"}, []string{"a", "a", "b"}, "a\tcount\tpercent\nb\t1\t33.33\n"},
+	}
+	for _, test := range tests {
+		func() {
+			defer setFlags(t, test.args...)()
+
+			b := &bytes.Buffer{}
+			w, _, err := newWriter(csvp.NewHeaders("a"), b)
+			if err != nil {
+				t.Fatalf("%q: newWriter returns %q, want nil", test.args, err)
+			}
+			w.WriteHeaders([]string{"a"})
+			for _, field := range test.src {
+				if err := w.Write([]string{field}); err != nil {
+					break
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Errorf("%q: Flush returns %q, want nil", test.args, err)
+			}
+			if actual := b.String(); actual != test.expect {
+				t.Errorf("%q: got %q, want %q", test.args, actual, test.expect)
+			}
+		}()
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	tests := []struct {
+		args  []string
+		valid bool
+	}{
+		{[]string{}, true},
+		{[]string{"--jobs=0"}, false},
+		{[]string{"--parallel-files=0"}, false},
+		{[]string{"--stats", "--stats-top=0"}, true},
+		{[]string{"--stats", "--stats-top=-1"}, false},
+		{[]string{"--list-headers"}, true},
+		{[]string{"--list-headers", "--indexes=1"}, false},
+		{[]string{"--list-headers", "--headers=a"}, false},
+		{[]string{"--list-headers", "--select=all:"}, false},
+		{[]string{"--partition-by=a"}, true},
+		{[]string{"--chunk-rows=10"}, true},
+		{[]string{"--partition-by=a", "--chunk-rows=10"}, false},
+		{[]string{"--partition-template={a}.csv", "--chunk-bytes=100"}, false},
+		{[]string{"--output=out.csv"}, true},
+		{[]string{"--output=out.csv", "--partition-by=a"}, false},
+		{[]string{"--output=out.csv", "--chunk-rows=10"}, false},
+		{[]string{"--transpose"}, true},
+		{[]string{"--transpose", "--partition-by=a"}, false},
+		{[]string{"--transpose", "--chunk-rows=10"}, false},
+	}
+	for _, test := range tests {
+		func() {
+			defer setFlags(t, test.args...)()
+
+			err := checkFlags()
+			if test.valid && err != nil {
+				t.Errorf("%q: checkFlags returns %q, want nil", test.args, err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("%q: checkFlags returns nil, want error", test.args)
+			}
+		}()
+	}
+}
+
+func TestListHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []struct {
+		name string
+		src  string
+	}{
+		{"a.csv", "a,b\n1,2\n"},
+		{"b.csv", "c\n3\n"},
+		{"empty.csv", ""},
+	}
+	paths := make(map[string]string)
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := ioutil.WriteFile(path, []byte(file.src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		paths[file.name] = path
+	}
+
+	tests := []struct {
+		args    []string
+		files   []string
+		records [][]string
+	}{
+		{
+			files:   []string{"a.csv"},
+			records: [][]string{{"1", "a"}, {"2", "b"}},
+		},
+		{
+			args:    []string{"--list-headers-sample"},
+			files:   []string{"a.csv"},
+			records: [][]string{{"1", "a", "1"}, {"2", "b", "2"}},
+		},
+		{
+			files: []string{"a.csv", "b.csv"},
+			records: [][]string{
+				{paths["a.csv"], "1", "a"},
+				{paths["a.csv"], "2", "b"},
+				{paths["b.csv"], "1", "c"},
+			},
+		},
+		{
+			files:   []string{"empty.csv"},
+			records: nil,
+		},
+		{
+			files: []string{"empty.csv", "b.csv"},
+			records: [][]string{
+				{paths["b.csv"], "1", "c"},
+			},
+		},
+	}
+	for _, test := range tests {
+		func() {
+			defer setFlags(t, test.args...)()
+
+			var files []string
+			for _, name := range test.files {
+				files = append(files, paths[name])
+			}
+			c := csvp.NewCSVScanner(csvp.NewAll(), nil)
+			w := &DummyWriter{}
+			if err := listHeaders(context.Background(), c, w, files, skipNone); err != nil {
+				t.Errorf("%q %q: listHeaders returns %q, want nil",
+					test.args, test.files, err)
+			}
+			if !reflect.DeepEqual(w.records, test.records) {
+				t.Errorf("%q %q:\ngot:\n%s\nwant:\n%s", test.args, test.files,
+					toLines(w.records), toLines(test.records))
+			}
+		}()
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+const defaultMaxOpenFiles = 64
+
+// escapeFilename escapes s to be used as a part of a filename safely.
+// Bytes other than ASCII letters, digits, "-", and "." are escaped like "%2F",
+// and a leading "." is escaped too.
+func escapeFilename(s string) string {
+	if s == "" {
+		return "_"
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == '-':
+			b = append(b, ch)
+		case ch == '.' && i > 0:
+			b = append(b, ch)
+		default:
+			b = append(b, fmt.Sprintf("%%%02X", ch)...)
+		}
+	}
+	return string(b)
+}
+
+var exprPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+type templatePart struct {
+	literal string
+	header  string
+	index   int
+}
+
+func parseTemplate(template string) []*templatePart {
+	parts := make([]*templatePart, 0)
+	for template != "" {
+		m := exprPlaceholder.FindStringSubmatchIndex(template)
+		if m == nil {
+			parts = append(parts, &templatePart{literal: template, index: -1})
+			break
+		}
+		if m[0] > 0 {
+			parts = append(parts, &templatePart{literal: template[:m[0]], index: -1})
+		}
+		parts = append(parts, &templatePart{header: template[m[2]:m[3]]})
+		template = template[m[1]:]
+	}
+	return parts
+}
+
+type partitionFile struct {
+	path string
+	f    *os.File
+	p    *Printer
+}
+
+// Partitioner writes records into files for each value of the specified headers.
+type Partitioner struct {
+	dir             string
+	outputDelimiter string
+	template        []*templatePart
+	maxOpenFiles    int
+	headers         []string
+	files           map[string]*list.Element
+	lru             *list.List
+	created         map[string]bool
+}
+
+// NewPartitioner returns a Partitioner which writes records into the files
+// under dir named by template, like "{customer}/{date}.csv".
+// The template defaults to headers in by joined with "_".
+func NewPartitioner(dir string, by string, template string, outputDelimiter string) (*Partitioner, error) {
+	if template == "" {
+		a := make([]string, 0)
+		for _, header := range csvp.ParseHeaderList(by) {
+			a = append(a, "{"+header+"}")
+		}
+		template = strings.Join(a, "_")
+	}
+	parts := parseTemplate(template)
+	found := false
+	for _, part := range parts {
+		if part.index != -1 {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%q: no headers in the partition template", template)
+	}
+
+	return &Partitioner{
+		dir:             dir,
+		outputDelimiter: outputDelimiter,
+		template:        parts,
+		maxOpenFiles:    defaultMaxOpenFiles,
+		files:           make(map[string]*list.Element),
+		lru:             list.New(),
+		created:         make(map[string]bool),
+	}, nil
+}
+
+func (p *Partitioner) SetMaxOpenFiles(n int) {
+	p.maxOpenFiles = n
+}
+
+func (p *Partitioner) WriteHeaders(headers []string) error {
+	if p.headers != nil {
+		return nil
+	}
+	for _, part := range p.template {
+		if part.index == -1 {
+			continue
+		}
+		part.index = headerIndex(headers, part.header)
+		if part.index == -1 {
+			return fmt.Errorf("%q: no such header", part.header)
+		}
+	}
+	p.headers = headers
+	return nil
+}
+
+func (p *Partitioner) path(record []string) string {
+	a := make([]string, len(p.template))
+	for i, part := range p.template {
+		switch {
+		case part.index == -1:
+			a[i] = part.literal
+		case part.index < len(record):
+			a[i] = escapeFilename(record[part.index])
+		default:
+			a[i] = escapeFilename("")
+		}
+	}
+	return filepath.Join(p.dir, filepath.FromSlash(strings.Join(a, "")))
+}
+
+func (p *Partitioner) open(path string) (*partitionFile, error) {
+	if e, ok := p.files[path]; ok {
+		p.lru.MoveToFront(e)
+		return e.Value.(*partitionFile), nil
+	}
+
+	for p.lru.Len() >= p.maxOpenFiles && p.lru.Len() > 0 {
+		if err := p.closeFile(p.lru.Back()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !p.created[path] {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	pf := &partitionFile{
+		path: path,
+		f:    f,
+		p:    NewPrinter(f, p.outputDelimiter, true),
+	}
+	if !p.created[path] {
+		p.created[path] = true
+		if err := pf.p.WriteHeaders(p.headers); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	p.files[path] = p.lru.PushFront(pf)
+	return pf, nil
+}
+
+func (p *Partitioner) closeFile(e *list.Element) error {
+	pf := e.Value.(*partitionFile)
+	p.lru.Remove(e)
+	delete(p.files, pf.path)
+	err := pf.p.Flush()
+	if cerr := pf.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Write writes record into the file for its values of the headers.
+// The headers must be written first to find the values in record.
+func (p *Partitioner) Write(record []string) error {
+	if p.headers == nil {
+		return errors.New("no headers to partition records by")
+	}
+	pf, err := p.open(p.path(record))
+	if err != nil {
+		return err
+	}
+	return pf.p.Write(record)
+}
+
+func (p *Partitioner) Flush() error {
+	for p.lru.Len() > 0 {
+		if err := p.closeFile(p.lru.Back()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Abort closes the open files, and removes all the files written by p.
+func (p *Partitioner) Abort() error {
+	var err error
+	for p.lru.Len() > 0 {
+		e := p.lru.Back()
+		p.lru.Remove(e)
+		pf := e.Value.(*partitionFile)
+		delete(p.files, pf.path)
+		pf.f.Close()
+	}
+	for path := range p.created {
+		if rerr := os.Remove(path); err == nil {
+			err = rerr
+		}
+		delete(p.created, path)
+	}
+	return err
+}
+
+func (p *Partitioner) Close() error {
+	for p.lru.Len() > 0 {
+		p.closeFile(p.lru.Back())
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var escapeFilenameTests = []struct {
+	src string
+	dst string
+}{
+	{src: "", dst: "_"},
+	{src: "acme", dst: "acme"},
+	{src: "Acme-01.v2", dst: "Acme-01.v2"},
+	{src: "a b/c", dst: "a%20b%2Fc"},
+	{src: "..", dst: "%2E."},
+	{src: ".hidden", dst: "%2Ehidden"},
+	{src: "a_b", dst: "a%5Fb"},
+	{src: "日", dst: "%E6%97%A5"},
+}
+
+func TestEscapeFilename(t *testing.T) {
+	for _, test := range escapeFilenameTests {
+		expect := test.dst
+		actual := escapeFilename(test.src)
+		if actual != expect {
+			t.Errorf("escapeFilename(%q) = %q, want %q",
+				test.src, actual, expect)
+		}
+	}
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+var partitionerTests = []struct {
+	list         string
+	template     string
+	maxOpenFiles int
+	files        map[string]string
+}{
+	{
+		list:         "customer",
+		maxOpenFiles: defaultMaxOpenFiles,
+		files: map[string]string{
+			"acme":       "customer,date,amount\nacme,2016-01-01,10\nacme,2016-01-02,30\n",
+			"bob%20%26c": "customer,date,amount\nbob &c,2016-01-01,20\n",
+			"_":          "customer,date,amount\n,2016-01-03,40\n",
+		},
+	},
+	{
+		list:         "customer",
+		maxOpenFiles: 1,
+		files: map[string]string{
+			"acme":       "customer,date,amount\nacme,2016-01-01,10\nacme,2016-01-02,30\n",
+			"bob%20%26c": "customer,date,amount\nbob &c,2016-01-01,20\n",
+			"_":          "customer,date,amount\n,2016-01-03,40\n",
+		},
+	},
+	{
+		template:     "{customer}/{date}.csv",
+		maxOpenFiles: 2,
+		files: map[string]string{
+			"acme/2016-01-01.csv":       "customer,date,amount\nacme,2016-01-01,10\n",
+			"bob%20%26c/2016-01-01.csv": "customer,date,amount\nbob &c,2016-01-01,20\n",
+			"acme/2016-01-02.csv":       "customer,date,amount\nacme,2016-01-02,30\n",
+			"_/2016-01-03.csv":          "customer,date,amount\n,2016-01-03,40\n",
+		},
+	},
+}
+
+func TestPartitioner(t *testing.T) {
+	headers := []string{"customer", "date", "amount"}
+	src := [][]string{
+		{"acme", "2016-01-01", "10"},
+		{"bob &c", "2016-01-01", "20"},
+		{"acme", "2016-01-02", "30"},
+		{"", "2016-01-03", "40"},
+	}
+	for _, test := range partitionerTests {
+		dir, err := ioutil.TempDir("", "csvp-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		p, err := NewPartitioner(dir, test.list, test.template, ",")
+		if err != nil {
+			t.Errorf("NewPartitioner(%q, %q) returns %q, want nil",
+				test.list, test.template, err)
+			continue
+		}
+		p.SetMaxOpenFiles(test.maxOpenFiles)
+		if err = p.WriteHeaders(headers); err != nil {
+			t.Errorf("WriteHeaders(%q) returns %q, want nil", headers, err)
+			continue
+		}
+		for _, record := range src {
+			if err = p.Write(record); err != nil {
+				t.Errorf("Write(%q) returns %q, want nil", record, err)
+			}
+			if p.lru.Len() > test.maxOpenFiles {
+				t.Errorf("%d files are open, want at most %d",
+					p.lru.Len(), test.maxOpenFiles)
+			}
+		}
+		if err = p.Flush(); err != nil {
+			t.Errorf("Flush() returns %q, want nil", err)
+		}
+
+		actual := readTree(t, dir)
+		for path, expect := range test.files {
+			if actual[path] != expect {
+				t.Errorf("list=%q, template=%q: %s:\ngot:\n%s\nwant:\n%s",
+					test.list, test.template, path, actual[path], expect)
+			}
+		}
+		if len(actual) != len(test.files) {
+			paths := make([]string, 0)
+			for path := range actual {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			t.Errorf("list=%q, template=%q: files = %s",
+				test.list, test.template, strings.Join(paths, ", "))
+		}
+	}
+}
+
+func TestPartitionerAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := NewPartitioner(dir, "", "{customer}/{date}.csv", ",")
+	if err != nil {
+		t.Fatalf("NewPartitioner returns %q, want nil", err)
+	}
+	p.SetMaxOpenFiles(1)
+	p.WriteHeaders([]string{"customer", "date"})
+	for _, record := range [][]string{{"acme", "2016-01-01"}, {"bob", "2016-01-01"}, {"acme", "2016-01-02"}} {
+		if err = p.Write(record); err != nil {
+			t.Errorf("Write(%q) returns %q, want nil", record, err)
+		}
+	}
+	if err = p.Abort(); err != nil {
+		t.Errorf("Abort() returns %q, want nil", err)
+	}
+	if err = p.Close(); err != nil {
+		t.Errorf("Close() after Abort() returns %q, want nil", err)
+	}
+	if files := readTree(t, dir); len(files) != 0 {
+		t.Errorf("files remain after Abort(): %q", files)
+	}
+}
+
+func TestPartitionerError(t *testing.T) {
+	if _, err := NewPartitioner(".", "", "out.csv", ","); err == nil {
+		t.Errorf("NewPartitioner without headers returns nil, want err")
+	}
+	p, _ := NewPartitioner(".", "region", "", ",")
+	if err := p.WriteHeaders([]string{"customer"}); err == nil {
+		t.Errorf("WriteHeaders() without region returns nil, want err")
+	}
+
+	p, _ = NewPartitioner(".", "region", "", ",")
+	if err := p.Write([]string{"east"}); err == nil {
+		t.Errorf("Write() before WriteHeaders() returns nil, want err")
+	}
+}
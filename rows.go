@@ -0,0 +1,110 @@
+package main
+
+import (
+	"github.com/nil-two/csvp/csvp"
+)
+
+// Rows selects records by their numbers, which start from 1 after headers.
+type Rows struct {
+	w      Writer
+	ranges []csvp.Range
+	head   int
+	tail   int
+	n      int
+	m      int
+	ring   [][]string
+	next   int
+}
+
+// NewRows returns a Rows which selects records in list,
+// and then the first head records and the last tail records.
+// A negative head or tail means no limit.
+func NewRows(w Writer, list string, head int, tail int) (*Rows, error) {
+	ranges, err := csvp.ParseRanges(list)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{
+		w:      w,
+		ranges: ranges,
+		head:   head,
+		tail:   tail,
+	}, nil
+}
+
+func (r *Rows) WriteHeaders(headers []string) error {
+	return r.w.WriteHeaders(headers)
+}
+
+func (r *Rows) contains(n int) bool {
+	if r.ranges == nil {
+		return true
+	}
+	for _, rr := range r.ranges {
+		if n >= rr.First && (rr.Last == 0 || n <= rr.Last) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rows) exhausted(n int) bool {
+	if r.ranges == nil {
+		return false
+	}
+	for _, rr := range r.ranges {
+		if rr.Last == 0 || n < rr.Last {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rows) Write(record []string) error {
+	if r.head == 0 {
+		return errStop
+	}
+
+	r.n++
+	if !r.contains(r.n) {
+		if r.exhausted(r.n) {
+			return errStop
+		}
+		return nil
+	}
+	r.m++
+
+	switch {
+	case r.tail == 0:
+	case r.tail > 0:
+		if len(r.ring) < r.tail {
+			r.ring = append(r.ring, record)
+		} else {
+			r.ring[r.next] = record
+			r.next = (r.next + 1) % r.tail
+		}
+	default:
+		if err := r.w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	if r.m == r.head || r.exhausted(r.n) {
+		return errStop
+	}
+	return nil
+}
+
+func (r *Rows) Flush() error {
+	for i := 0; i < len(r.ring); i++ {
+		if err := r.w.Write(r.ring[(r.next+i)%len(r.ring)]); err != nil {
+			return err
+		}
+	}
+	r.ring = nil
+	return r.w.Flush()
+}
+
+func (r *Rows) Close() error {
+	return r.w.Close()
+}
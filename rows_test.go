@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+var rowsTests = []struct {
+	list    string
+	head    int
+	tail    int
+	dst     []int
+	stopped bool
+}{
+	{list: "", head: -1, tail: -1, dst: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	{list: "3", head: -1, tail: -1, dst: []int{3}, stopped: true},
+	{list: "5,2", head: -1, tail: -1, dst: []int{2, 5}, stopped: true},
+	{list: "2-4,8-", head: -1, tail: -1, dst: []int{2, 3, 4, 8, 9, 10}},
+	{list: "-3", head: -1, tail: -1, dst: []int{1, 2, 3}, stopped: true},
+	{list: "-", head: -1, tail: -1, dst: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	{list: "20-", head: -1, tail: -1, dst: []int{}},
+	{list: "", head: 3, tail: -1, dst: []int{1, 2, 3}, stopped: true},
+	{list: "", head: 0, tail: -1, dst: []int{}, stopped: true},
+	{list: "", head: 20, tail: -1, dst: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	{list: "", head: -1, tail: 3, dst: []int{8, 9, 10}},
+	{list: "", head: -1, tail: 0, dst: []int{}},
+	{list: "", head: -1, tail: 20, dst: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	{list: "2-", head: 5, tail: 2, dst: []int{5, 6}, stopped: true},
+}
+
+func TestRows(t *testing.T) {
+	for _, test := range rowsTests {
+		w := &DummyWriter{}
+		r, err := NewRows(w, test.list, test.head, test.tail)
+		if err != nil {
+			t.Errorf("NewRows(%q, %d, %d) returns %q, want nil",
+				test.list, test.head, test.tail, err)
+			continue
+		}
+		self := fmt.Sprintf("{list=%q, head=%d, tail=%d}",
+			test.list, test.head, test.tail)
+
+		stopped := false
+		for i := 1; i <= 10 && !stopped; i++ {
+			err = r.Write([]string{strconv.Itoa(i)})
+			switch {
+			case err == errStop:
+				stopped = true
+			case err != nil:
+				t.Errorf("%s.Write() returns %q, want nil", self, err)
+			}
+		}
+		if err = r.Flush(); err != nil {
+			t.Errorf("%s.Flush() returns %q, want nil", self, err)
+		}
+
+		if stopped != test.stopped {
+			t.Errorf("%s: stopped = %v, want %v", self, stopped, test.stopped)
+		}
+		expect := make([][]string, len(test.dst))
+		for i, n := range test.dst {
+			expect[i] = []string{strconv.Itoa(n)}
+		}
+		actual := w.records
+		if len(actual) == 0 && len(expect) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s",
+				self, toLines(actual), toLines(expect))
+		}
+	}
+}
+
+func TestRowsInvalidList(t *testing.T) {
+	for _, list := range []string{"0", ",,", "--", "a", "1\\,2"} {
+		if _, err := NewRows(&DummyWriter{}, list, -1, -1); err == nil {
+			t.Errorf("NewRows(%q) returns nil, want err", list)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// signalContext returns a copy of parent which is canceled on SIGINT or SIGTERM.
+// The returned stop function stops the notification,
+// and returns the received signal or nil.
+// After the first signal, the next one terminates the process as usual.
+func signalContext(parent context.Context) (context.Context, func() os.Signal) {
+	ctx, cancel := context.WithCancel(parent)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	var received os.Signal
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case received = <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return ctx, func() os.Signal {
+		cancel()
+		<-done
+		return received
+	}
+}
+
+// signalStatus returns the exit status for termination by sig.
+func signalStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
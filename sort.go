@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+const (
+	defaultSortBufferSize = 64 << 20
+	defaultSortFanIn      = 64
+)
+
+type comparator struct {
+	validate func(s string) error
+	compare  func(a, b string) int
+}
+
+var comparators = map[string]comparator{
+	"str":  {validate: validateString, compare: compareString},
+	"num":  {validate: validateNumber, compare: compareNumber},
+	"nat":  {validate: validateString, compare: compareNatural},
+	"date": {validate: validateDate, compare: compareDate},
+}
+
+func validateString(s string) error {
+	return nil
+}
+
+func compareString(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+func toNumber(s string) (float64, error) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q: not a number", s)
+	}
+	return n, nil
+}
+
+func validateNumber(s string) error {
+	_, err := toNumber(s)
+	return err
+}
+
+func compareNumber(a, b string) int {
+	x, _ := toNumber(a)
+	y, _ := toNumber(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareNatural(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	for len(x) > 0 && len(y) > 0 {
+		if !unicode.IsDigit(x[0]) || !unicode.IsDigit(y[0]) {
+			if x[0] != y[0] {
+				if x[0] < y[0] {
+					return -1
+				}
+				return 1
+			}
+			x, y = x[1:], y[1:]
+			continue
+		}
+
+		i, j := 0, 0
+		for i < len(x) && unicode.IsDigit(x[i]) {
+			i++
+		}
+		for j < len(y) && unicode.IsDigit(y[j]) {
+			j++
+		}
+		m := strings.TrimLeft(string(x[:i]), "0")
+		n := strings.TrimLeft(string(y[:j]), "0")
+		switch {
+		case len(m) != len(n):
+			if len(m) < len(n) {
+				return -1
+			}
+			return 1
+		case m != n:
+			return strings.Compare(m, n)
+		}
+		x, y = x[i:], y[j:]
+	}
+	return len(x) - len(y)
+}
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006/01/02",
+	"2006/01/02 15:04:05",
+}
+
+func toDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q: not a date", s)
+}
+
+func validateDate(s string) error {
+	_, err := toDate(s)
+	return err
+}
+
+func compareDate(a, b string) int {
+	x, _ := toDate(a)
+	y, _ := toDate(b)
+	switch {
+	case x.Before(y):
+		return -1
+	case x.After(y):
+		return 1
+	}
+	return 0
+}
+
+type SortKey struct {
+	header string
+	index  int
+	desc   bool
+	comparator
+}
+
+func parseSortKey(s string) (*SortKey, error) {
+	a := splitList(s, ':')
+	if len(a) == 0 {
+		return nil, fmt.Errorf("%q: invalid sort key", s)
+	}
+
+	k := &SortKey{
+		header:     csvp.Unescape(a[0]),
+		comparator: comparators["str"],
+	}
+	for _, option := range a[1:] {
+		switch option {
+		case "asc":
+			k.desc = false
+		case "desc":
+			k.desc = true
+		default:
+			c, ok := comparators[option]
+			if !ok {
+				return nil, fmt.Errorf("%q: unknown sort option", option)
+			}
+			k.comparator = c
+		}
+	}
+	return k, nil
+}
+
+type Sorter struct {
+	w             Writer
+	keys          []*SortKey
+	bufferSize    int
+	fanIn         int
+	size          int
+	records       [][]string
+	chunks        []string
+	parsedHeaders bool
+}
+
+func NewSorter(w Writer, list string) (*Sorter, error) {
+	keys := make([]*SortKey, 0)
+	for _, s := range splitList(list, ',') {
+		k, err := parseSortKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no sort keys specified")
+	}
+	return &Sorter{
+		w:          w,
+		keys:       keys,
+		bufferSize: defaultSortBufferSize,
+		fanIn:      defaultSortFanIn,
+	}, nil
+}
+
+func (s *Sorter) SetBufferSize(size int) {
+	s.bufferSize = size
+}
+
+// SetFanIn sets the number of chunks merged at once.
+func (s *Sorter) SetFanIn(n int) {
+	if n < 2 {
+		n = 2
+	}
+	s.fanIn = n
+}
+
+// WriteHeaders resolves the sort keys for every file,
+// since the files may order their columns differently.
+func (s *Sorter) WriteHeaders(headers []string) error {
+	for _, k := range s.keys {
+		k.index = headerIndex(headers, k.header)
+		if k.index == -1 {
+			return fmt.Errorf("%q: no such header", k.header)
+		}
+	}
+	if s.parsedHeaders {
+		return nil
+	}
+	s.parsedHeaders = true
+	return s.w.WriteHeaders(headers)
+}
+
+// Write stores the record behind a copy of its key fields,
+// so records of files with different column orders compare alike.
+func (s *Sorter) Write(record []string) error {
+	entry := make([]string, len(s.keys), len(s.keys)+len(record))
+	for i, k := range s.keys {
+		if k.index >= len(record) {
+			return fmt.Errorf("%q: missing in record %q", k.header, record)
+		}
+		if err := k.validate(record[k.index]); err != nil {
+			return err
+		}
+		entry[i] = record[k.index]
+	}
+	entry = append(entry, record...)
+
+	s.records = append(s.records, entry)
+	s.size += recordSize(entry)
+	if s.size >= s.bufferSize {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *Sorter) less(a, b []string) bool {
+	for i, k := range s.keys {
+		c := k.compare(a[i], b[i])
+		if k.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+func (s *Sorter) output(entry []string) error {
+	return s.w.Write(entry[len(s.keys):])
+}
+
+func (s *Sorter) sortRecords() {
+	sort.SliceStable(s.records, func(i, j int) bool {
+		return s.less(s.records[i], s.records[j])
+	})
+}
+
+// createChunk creates a temporary file for a chunk and records its name,
+// so that Close removes it even if writing the chunk fails.
+func (s *Sorter) createChunk() (*os.File, error) {
+	f, err := ioutil.TempFile("", "csvp-sort-")
+	if err != nil {
+		return nil, err
+	}
+	s.chunks = append(s.chunks, f.Name())
+	return f, nil
+}
+
+func (s *Sorter) spill() error {
+	s.sortRecords()
+
+	f, err := s.createChunk()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	enc := gob.NewEncoder(bw)
+	for _, record := range s.records {
+		if err := enc.Encode(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	s.records = nil
+	s.size = 0
+	return nil
+}
+
+func (s *Sorter) Flush() error {
+	if len(s.chunks) == 0 {
+		s.sortRecords()
+		for _, record := range s.records {
+			if err := s.output(record); err != nil {
+				if err == errStop {
+					break
+				}
+				return err
+			}
+		}
+		s.records = nil
+		return s.w.Flush()
+	}
+
+	if len(s.records) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	if err := s.merge(); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+type chunkReader struct {
+	order  int
+	record []string
+	dec    *gob.Decoder
+}
+
+type chunkHeap struct {
+	s  *Sorter
+	rs []*chunkReader
+}
+
+func (h *chunkHeap) Len() int {
+	return len(h.rs)
+}
+
+func (h *chunkHeap) Less(i, j int) bool {
+	switch {
+	case h.s.less(h.rs[i].record, h.rs[j].record):
+		return true
+	case h.s.less(h.rs[j].record, h.rs[i].record):
+		return false
+	}
+	return h.rs[i].order < h.rs[j].order
+}
+
+func (h *chunkHeap) Swap(i, j int) {
+	h.rs[i], h.rs[j] = h.rs[j], h.rs[i]
+}
+
+func (h *chunkHeap) Push(x interface{}) {
+	h.rs = append(h.rs, x.(*chunkReader))
+}
+
+func (h *chunkHeap) Pop() interface{} {
+	r := h.rs[len(h.rs)-1]
+	h.rs = h.rs[:len(h.rs)-1]
+	return r
+}
+
+// merge merges the chunks in passes of at most s.fanIn chunks,
+// so that no more than s.fanIn files are open at once.
+// Each pass replaces the first chunks with their merge,
+// which keeps the chunks in input order and the sort stable.
+func (s *Sorter) merge() error {
+	for len(s.chunks) > s.fanIn {
+		names := s.chunks[:s.fanIn]
+		f, err := s.createChunk()
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(f)
+		enc := gob.NewEncoder(bw)
+		err = s.mergeChunks(names, func(record []string) error {
+			return enc.Encode(record)
+		})
+		if err == nil {
+			err = bw.Flush()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			os.Remove(name)
+		}
+		merged := s.chunks[len(s.chunks)-1]
+		s.chunks = append([]string{merged}, s.chunks[s.fanIn:len(s.chunks)-1]...)
+	}
+
+	err := s.mergeChunks(s.chunks, s.output)
+	if err == errStop {
+		return nil
+	}
+	return err
+}
+
+func (s *Sorter) mergeChunks(names []string, write func(record []string) error) error {
+	h := &chunkHeap{s: s}
+	for i, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r := &chunkReader{
+			order: i,
+			dec:   gob.NewDecoder(bufio.NewReader(f)),
+		}
+		if err := r.dec.Decode(&r.record); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h.rs = append(h.rs, r)
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		r := h.rs[0]
+		if err := write(r.record); err != nil {
+			return err
+		}
+
+		r.record = nil
+		err := r.dec.Decode(&r.record)
+		switch {
+		case err == io.EOF:
+			heap.Pop(h)
+		case err != nil:
+			return err
+		default:
+			heap.Fix(h, 0)
+		}
+	}
+	return nil
+}
+
+func (s *Sorter) Close() error {
+	for _, name := range s.chunks {
+		os.Remove(name)
+	}
+	s.chunks = nil
+	return s.w.Close()
+}
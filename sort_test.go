@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var compareNaturalTests = []struct {
+	a      string
+	b      string
+	result int
+}{
+	{a: "a", b: "a", result: 0},
+	{a: "a", b: "b", result: -1},
+	{a: "file2", b: "file10", result: -1},
+	{a: "file10", b: "file2", result: 1},
+	{a: "file02", b: "file2", result: 0},
+	{a: "v1.10", b: "v1.9", result: 1},
+	{a: "x", b: "x1", result: -1},
+}
+
+func TestCompareNatural(t *testing.T) {
+	for _, test := range compareNaturalTests {
+		expect := test.result
+		actual := compareNatural(test.a, test.b)
+		if actual < 0 {
+			actual = -1
+		}
+		if actual > 0 {
+			actual = 1
+		}
+		if actual != expect {
+			t.Errorf("compareNatural(%q, %q) = %d, want %d",
+				test.a, test.b, actual, expect)
+		}
+	}
+}
+
+var sortItems = [][]string{
+	{"Apple", "60", "2016-02-01"},
+	{"Grapes", "140", "2015-12-24"},
+	{"Pineapple", "400", "2016-01-10"},
+	{"Orange", "60", "2015-11-30"},
+	{"Banana", "140", "2016-04-27"},
+}
+
+var sorterTests = []struct {
+	list string
+	dst  [][]string
+}{
+	{
+		list: "name",
+		dst: [][]string{
+			{"Apple", "60", "2016-02-01"},
+			{"Banana", "140", "2016-04-27"},
+			{"Grapes", "140", "2015-12-24"},
+			{"Orange", "60", "2015-11-30"},
+			{"Pineapple", "400", "2016-01-10"},
+		},
+	},
+	{
+		list: "price:num",
+		dst: [][]string{
+			{"Apple", "60", "2016-02-01"},
+			{"Orange", "60", "2015-11-30"},
+			{"Grapes", "140", "2015-12-24"},
+			{"Banana", "140", "2016-04-27"},
+			{"Pineapple", "400", "2016-01-10"},
+		},
+	},
+	{
+		list: "price:num:desc,name",
+		dst: [][]string{
+			{"Pineapple", "400", "2016-01-10"},
+			{"Banana", "140", "2016-04-27"},
+			{"Grapes", "140", "2015-12-24"},
+			{"Apple", "60", "2016-02-01"},
+			{"Orange", "60", "2015-11-30"},
+		},
+	},
+	{
+		list: "date:date:desc",
+		dst: [][]string{
+			{"Banana", "140", "2016-04-27"},
+			{"Apple", "60", "2016-02-01"},
+			{"Pineapple", "400", "2016-01-10"},
+			{"Grapes", "140", "2015-12-24"},
+			{"Orange", "60", "2015-11-30"},
+		},
+	},
+}
+
+func TestSorter(t *testing.T) {
+	headers := []string{"name", "price", "date"}
+	for _, bufferSize := range []int{defaultSortBufferSize, 1, 100} {
+		for _, test := range sorterTests {
+			for _, fanIn := range []int{defaultSortFanIn, 2} {
+				w := &DummyWriter{}
+				s, err := NewSorter(w, test.list)
+				if err != nil {
+					t.Errorf("NewSorter(%q) returns %q, want nil",
+						test.list, err)
+					continue
+				}
+				s.SetBufferSize(bufferSize)
+				s.SetFanIn(fanIn)
+				self := fmt.Sprintf("{list=%q, bufferSize=%d, fanIn=%d}",
+					test.list, bufferSize, fanIn)
+
+				if err = s.WriteHeaders(headers); err != nil {
+					t.Errorf("%s.WriteHeaders(%q) returns %q, want nil",
+						self, headers, err)
+					continue
+				}
+				for _, record := range sortItems {
+					if err = s.Write(record); err != nil {
+						t.Errorf("%s.Write(%q) returns %q, want nil",
+							self, record, err)
+					}
+				}
+				if err = s.Flush(); err != nil {
+					t.Errorf("%s.Flush() returns %q, want nil", self, err)
+				}
+				if err = s.Close(); err != nil {
+					t.Errorf("%s.Close() returns %q, want nil", self, err)
+				}
+
+				expect := test.dst
+				actual := w.records
+				if !reflect.DeepEqual(actual, expect) {
+					t.Errorf("%s:\ngot:\n%s\nwant:\n%s",
+						self, toLines(actual), toLines(expect))
+				}
+			}
+		}
+	}
+}
+
+func TestSorterStop(t *testing.T) {
+	headers := []string{"name", "price", "date"}
+	for _, bufferSize := range []int{defaultSortBufferSize, 1, 100} {
+		w := &DummyWriter{}
+		r, err := NewRows(w, "", 2, -1)
+		if err != nil {
+			t.Fatalf("NewRows returns %q, want nil", err)
+		}
+		s, err := NewSorter(r, "price:num:desc,name")
+		if err != nil {
+			t.Fatalf("NewSorter returns %q, want nil", err)
+		}
+		s.SetBufferSize(bufferSize)
+
+		s.WriteHeaders(headers)
+		for _, record := range sortItems {
+			s.Write(record)
+		}
+		if err = s.Flush(); err != nil {
+			t.Errorf("bufferSize=%d: Flush() returns %q, want nil", bufferSize, err)
+		}
+		s.Close()
+
+		expect := [][]string{
+			{"Pineapple", "400", "2016-01-10"},
+			{"Banana", "140", "2016-04-27"},
+		}
+		if !reflect.DeepEqual(w.records, expect) {
+			t.Errorf("bufferSize=%d:\ngot:\n%s\nwant:\n%s",
+				bufferSize, toLines(w.records), toLines(expect))
+		}
+		if !w.flushed {
+			t.Errorf("bufferSize=%d: the writer is not flushed", bufferSize)
+		}
+	}
+}
+
+func TestSorterHeadersPerFile(t *testing.T) {
+	files := []struct {
+		headers []string
+		records [][]string
+	}{
+		{
+			headers: []string{"name", "price"},
+			records: [][]string{{"Apple", "60"}, {"Grape", "300"}},
+		},
+		{
+			headers: []string{"price", "name"},
+			records: [][]string{{"140", "Banana"}, {"400", "Pineapple"}},
+		},
+	}
+	for _, bufferSize := range []int{defaultSortBufferSize, 1} {
+		w := &DummyWriter{}
+		s, err := NewSorter(w, "price:num")
+		if err != nil {
+			t.Fatalf("NewSorter returns %q, want nil", err)
+		}
+		s.SetBufferSize(bufferSize)
+		for _, file := range files {
+			if err := s.WriteHeaders(file.headers); err != nil {
+				t.Fatalf("WriteHeaders(%q) returns %q, want nil",
+					file.headers, err)
+			}
+			for _, record := range file.records {
+				if err := s.Write(record); err != nil {
+					t.Fatalf("Write(%q) returns %q, want nil", record, err)
+				}
+			}
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatalf("Flush() returns %q, want nil", err)
+		}
+		s.Close()
+
+		expect := [][]string{
+			{"Apple", "60"},
+			{"140", "Banana"},
+			{"Grape", "300"},
+			{"400", "Pineapple"},
+		}
+		if !reflect.DeepEqual(w.records, expect) {
+			t.Errorf("bufferSize=%d:\ngot:\n%s\nwant:\n%s",
+				bufferSize, toLines(w.records), toLines(expect))
+		}
+		expectHeaders := [][]string{files[0].headers}
+		if !reflect.DeepEqual(w.headers, expectHeaders) {
+			t.Errorf("bufferSize=%d: headers = %q, want %q",
+				bufferSize, w.headers, expectHeaders)
+		}
+	}
+}
+
+var sorterErrorTests = []struct {
+	list   string
+	record []string
+}{
+	{list: "price:foo", record: nil},
+	{list: "", record: nil},
+	{list: "date", record: nil},
+	{list: "price:num", record: []string{"Apple", "sixty"}},
+}
+
+func TestSorterError(t *testing.T) {
+	headers := []string{"name", "price"}
+	for _, test := range sorterErrorTests {
+		s, err := NewSorter(&DummyWriter{}, test.list)
+		if err == nil {
+			err = s.WriteHeaders(headers)
+		}
+		if err == nil && test.record != nil {
+			err = s.Write(test.record)
+		}
+		if err == nil {
+			t.Errorf("sorting by %q returns nil, want err", test.list)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+type splitting struct {
+	header string
+	sep    string
+	names  []string
+	index  int
+}
+
+// Splitter splits a column into columns by a separator.
+type Splitter struct {
+	splittings []*splitting
+}
+
+// NewSplitter returns a Splitter for list of "HEADER=SEP:NAME,NAME..."
+// separated by ";".
+func NewSplitter(list string) (*Splitter, error) {
+	s := &Splitter{}
+	for _, item := range splitList(list, ';') {
+		header, rest, ok := splitOnce(item, '=')
+		if !ok {
+			return nil, fmt.Errorf("%q: invalid splitting", item)
+		}
+		sep, rawNames, ok := splitOnce(rest, ':')
+		if !ok || sep == "" || rawNames == "" {
+			return nil, fmt.Errorf("%q: invalid splitting", item)
+		}
+
+		sp := &splitting{
+			header: csvp.Unescape(header),
+			sep:    unescapeSeparators(sep, ";=:,"),
+		}
+		for _, name := range splitList(rawNames, ',') {
+			sp.names = append(sp.names, csvp.Unescape(name))
+		}
+		s.splittings = append(s.splittings, sp)
+	}
+	return s, nil
+}
+
+func (s *Splitter) ParseHeaders(headers []string) ([]string, error) {
+	for _, sp := range s.splittings {
+		sp.index = headerIndex(headers, sp.header)
+		if sp.index == -1 {
+			return nil, fmt.Errorf("%q: no such header", sp.header)
+		}
+		headers = replaceFields(headers, sp.index, sp.names)
+	}
+	return headers, nil
+}
+
+func (s *Splitter) Transform(record []string) ([]string, error) {
+	for _, sp := range s.splittings {
+		var value string
+		if sp.index < len(record) {
+			value = record[sp.index]
+		}
+		a := make([]string, len(sp.names))
+		copy(a, strings.SplitN(value, sp.sep, len(sp.names)))
+		record = replaceFields(record, sp.index, a)
+	}
+	return record, nil
+}
+
+// replaceFields returns record whose i-th field is replaced with fields.
+func replaceFields(record []string, i int, fields []string) []string {
+	for len(record) <= i {
+		record = append(record, "")
+	}
+	a := make([]string, 0, len(record)-1+len(fields))
+	a = append(a, record[:i]...)
+	a = append(a, fields...)
+	return append(a, record[i+1:]...)
+}
+
+type merging struct {
+	name    string
+	headers []string
+	joiner  string
+	indexes []int
+}
+
+// Merger merges columns into a column with a joiner.
+type Merger struct {
+	mergings []*merging
+}
+
+// NewMerger returns a Merger for list of "NAME=HEADER,HEADER...:JOINER"
+// separated by ";".
+func NewMerger(list string) (*Merger, error) {
+	m := &Merger{}
+	for _, item := range splitList(list, ';') {
+		name, rest, ok := splitOnce(item, '=')
+		if !ok {
+			return nil, fmt.Errorf("%q: invalid merging", item)
+		}
+		rawHeaders, joiner, ok := splitOnce(rest, ':')
+		if !ok || rawHeaders == "" {
+			return nil, fmt.Errorf("%q: invalid merging", item)
+		}
+
+		mg := &merging{
+			name:   csvp.Unescape(name),
+			joiner: unescapeSeparators(joiner, ";=:,"),
+		}
+		for _, header := range splitList(rawHeaders, ',') {
+			mg.headers = append(mg.headers, csvp.Unescape(header))
+		}
+		m.mergings = append(m.mergings, mg)
+	}
+	return m, nil
+}
+
+func (m *Merger) ParseHeaders(headers []string) ([]string, error) {
+	for _, mg := range m.mergings {
+		mg.indexes = make([]int, len(mg.headers))
+		for i, header := range mg.headers {
+			mg.indexes[i] = headerIndex(headers, header)
+			if mg.indexes[i] == -1 {
+				return nil, fmt.Errorf("%q: no such header", header)
+			}
+		}
+		headers = mergeFields(headers, mg.indexes, mg.name)
+	}
+	return headers, nil
+}
+
+func (m *Merger) Transform(record []string) ([]string, error) {
+	for _, mg := range m.mergings {
+		a := make([]string, len(mg.indexes))
+		for i, index := range mg.indexes {
+			if index < len(record) {
+				a[i] = record[index]
+			}
+		}
+		record = mergeFields(record, mg.indexes, strings.Join(a, mg.joiner))
+	}
+	return record, nil
+}
+
+// mergeFields returns record whose fields at indexes are replaced with
+// field at the position of the first of them.
+func mergeFields(record []string, indexes []int, field string) []string {
+	first := indexes[0]
+	merged := make(map[int]bool)
+	for _, index := range indexes {
+		merged[index] = true
+		if index < first {
+			first = index
+		}
+	}
+
+	a := make([]string, 0, len(record))
+	for i, f := range record {
+		switch {
+		case i == first:
+			a = append(a, field)
+		case !merged[i]:
+			a = append(a, f)
+		}
+	}
+	if first >= len(record) {
+		a = append(a, field)
+	}
+	return a
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var splitterTests = []struct {
+	list    string
+	headers []string
+	src     []string
+	dst     []string
+	dstH    []string
+}{
+	{
+		list:    "fullname= :first,last",
+		headers: []string{"id", "fullname", "age"},
+		src:     []string{"1", "John Smith", "30"},
+		dstH:    []string{"id", "first", "last", "age"},
+		dst:     []string{"1", "John", "Smith", "30"},
+	},
+	{
+		list:    "fullname= :first,last",
+		headers: []string{"id", "fullname", "age"},
+		src:     []string{"1", "Cher", "30"},
+		dstH:    []string{"id", "first", "last", "age"},
+		dst:     []string{"1", "Cher", "", "30"},
+	},
+	{
+		list:    "date=-:year,rest",
+		headers: []string{"date"},
+		src:     []string{"2016-04-27"},
+		dstH:    []string{"year", "rest"},
+		dst:     []string{"2016", "04-27"},
+	},
+	{
+		list:    `pair=\::a,b;a=/:x,y`,
+		headers: []string{"pair"},
+		src:     []string{"1/2:3"},
+		dstH:    []string{"x", "y", "b"},
+		dst:     []string{"1", "2", "3"},
+	},
+}
+
+func TestSplitter(t *testing.T) {
+	for _, test := range splitterTests {
+		s, err := NewSplitter(test.list)
+		if err != nil {
+			t.Errorf("NewSplitter(%q) returns %q, want nil", test.list, err)
+			continue
+		}
+		headers, err := s.ParseHeaders(test.headers)
+		if err != nil {
+			t.Errorf("NewSplitter(%q).ParseHeaders(%q) returns %q, want nil",
+				test.list, test.headers, err)
+			continue
+		}
+		if !reflect.DeepEqual(headers, test.dstH) {
+			t.Errorf("NewSplitter(%q).ParseHeaders(%q) = %q, want %q",
+				test.list, test.headers, headers, test.dstH)
+		}
+		record, err := s.Transform(test.src)
+		if err != nil {
+			t.Errorf("NewSplitter(%q).Transform(%q) returns %q, want nil",
+				test.list, test.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(record, test.dst) {
+			t.Errorf("NewSplitter(%q).Transform(%q) = %q, want %q",
+				test.list, test.src, record, test.dst)
+		}
+	}
+}
+
+var mergerTests = []struct {
+	list    string
+	headers []string
+	src     []string
+	dst     []string
+	dstH    []string
+}{
+	{
+		list:    "addr=street,city,zip: ",
+		headers: []string{"name", "street", "city", "zip"},
+		src:     []string{"Bob", "1 Main St", "Springfield", "12345"},
+		dstH:    []string{"name", "addr"},
+		dst:     []string{"Bob", "1 Main St Springfield 12345"},
+	},
+	{
+		list:    "key=zip,name:",
+		headers: []string{"name", "street", "city", "zip"},
+		src:     []string{"Bob", "1 Main St", "Springfield", "12345"},
+		dstH:    []string{"key", "street", "city"},
+		dst:     []string{"12345Bob", "1 Main St", "Springfield"},
+	},
+	{
+		list:    `place=street,city:\, ;all=place,name:: `,
+		headers: []string{"name", "street", "city"},
+		src:     []string{"Bob", "1 Main St", "Springfield"},
+		dstH:    []string{"all"},
+		dst:     []string{"1 Main St, Springfield: Bob"},
+	},
+}
+
+func TestMerger(t *testing.T) {
+	for _, test := range mergerTests {
+		m, err := NewMerger(test.list)
+		if err != nil {
+			t.Errorf("NewMerger(%q) returns %q, want nil", test.list, err)
+			continue
+		}
+		headers, err := m.ParseHeaders(test.headers)
+		if err != nil {
+			t.Errorf("NewMerger(%q).ParseHeaders(%q) returns %q, want nil",
+				test.list, test.headers, err)
+			continue
+		}
+		if !reflect.DeepEqual(headers, test.dstH) {
+			t.Errorf("NewMerger(%q).ParseHeaders(%q) = %q, want %q",
+				test.list, test.headers, headers, test.dstH)
+		}
+		record, err := m.Transform(test.src)
+		if err != nil {
+			t.Errorf("NewMerger(%q).Transform(%q) returns %q, want nil",
+				test.list, test.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(record, test.dst) {
+			t.Errorf("NewMerger(%q).Transform(%q) = %q, want %q",
+				test.list, test.src, record, test.dst)
+		}
+	}
+}
+
+func TestSplitMergeError(t *testing.T) {
+	headers := []string{"a", "b"}
+	for _, list := range []string{"a", "a=", "a=:x", "a= :", "c= :x,y"} {
+		s, err := NewSplitter(list)
+		if err == nil {
+			_, err = s.ParseHeaders(headers)
+		}
+		if err == nil {
+			t.Errorf("NewSplitter(%q) returns nil, want err", list)
+		}
+	}
+	for _, list := range []string{"x", "x=", "x=:-", "x=a,c:-"} {
+		m, err := NewMerger(list)
+		if err == nil {
+			_, err = m.ParseHeaders(headers)
+		}
+		if err == nil {
+			t.Errorf("NewMerger(%q) returns nil, want err", list)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type valueCount struct {
+	value string
+	count int
+}
+
+func sortValueCounts(a []valueCount) {
+	sort.SliceStable(a, func(i, j int) bool {
+		return a[i].count > a[j].count
+	})
+}
+
+type valueCounter interface {
+	add(s string)
+	distinct() int
+	top(n int) []valueCount
+}
+
+type exactCounter struct {
+	counts map[string]int
+	order  []string
+}
+
+func newExactCounter() *exactCounter {
+	return &exactCounter{
+		counts: make(map[string]int),
+	}
+}
+
+func (c *exactCounter) add(s string) {
+	if _, ok := c.counts[s]; !ok {
+		c.order = append(c.order, s)
+	}
+	c.counts[s]++
+}
+
+func (c *exactCounter) distinct() int {
+	return len(c.counts)
+}
+
+func (c *exactCounter) top(n int) []valueCount {
+	a := make([]valueCount, len(c.order))
+	for i, value := range c.order {
+		a[i] = valueCount{value: value, count: c.counts[value]}
+	}
+	sortValueCounts(a)
+	if n < len(a) {
+		a = a[:n]
+	}
+	return a
+}
+
+const hyperLogLogPrecision = 14
+
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{
+		registers: make([]uint8, 1<<hyperLogLogPrecision),
+	}
+}
+
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func (h *hyperLogLog) add(s string) {
+	x := hash64(s)
+	i := x >> (64 - hyperLogLogPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1)) + 1)
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+func (h *hyperLogLog) count() int {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int(e + 0.5)
+}
+
+// approxCounter counts values in bounded memory.
+// It estimates the number of distinct values with HyperLogLog,
+// and the most frequent values with the Space-Saving algorithm.
+// The counted values are kept in a min-heap by count,
+// so that the least frequent one is evicted in O(log n).
+type approxCounter struct {
+	hll      *hyperLogLog
+	capacity int
+	counts   map[string]*counterEntry
+	heap     counterHeap
+}
+
+type counterEntry struct {
+	value string
+	count int
+	index int
+}
+
+type counterHeap []*counterEntry
+
+func (h counterHeap) Len() int {
+	return len(h)
+}
+
+func (h counterHeap) Less(i, j int) bool {
+	return h[i].count < h[j].count
+}
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	e := x.(*counterEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+func newApproxCounter(capacity int) *approxCounter {
+	return &approxCounter{
+		hll:      newHyperLogLog(),
+		capacity: capacity,
+		counts:   make(map[string]*counterEntry),
+	}
+}
+
+func (c *approxCounter) add(s string) {
+	c.hll.add(s)
+	if e, ok := c.counts[s]; ok {
+		e.count++
+		heap.Fix(&c.heap, e.index)
+		return
+	}
+	if len(c.heap) < c.capacity {
+		e := &counterEntry{value: s, count: 1}
+		c.counts[s] = e
+		heap.Push(&c.heap, e)
+		return
+	}
+
+	e := c.heap[0]
+	delete(c.counts, e.value)
+	e.value = s
+	e.count++
+	c.counts[s] = e
+	heap.Fix(&c.heap, 0)
+}
+
+func (c *approxCounter) distinct() int {
+	return c.hll.count()
+}
+
+func (c *approxCounter) top(n int) []valueCount {
+	a := make([]valueCount, 0, len(c.counts))
+	for _, e := range c.heap {
+		a = append(a, valueCount{value: e.value, count: e.count})
+	}
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].count != a[j].count {
+			return a[i].count > a[j].count
+		}
+		return a[i].value < a[j].value
+	})
+	if n < len(a) {
+		a = a[:n]
+	}
+	return a
+}
+
+type columnStats struct {
+	count     int
+	maxLength int
+	isInt     bool
+	isNumber  bool
+	isDate    bool
+	sum       float64
+	minNumber float64
+	maxNumber float64
+	minString string
+	maxString string
+	minDate   time.Time
+	maxDate   time.Time
+	counter   valueCounter
+}
+
+func (c *columnStats) add(s string) {
+	if s == "" {
+		return
+	}
+	c.counter.add(s)
+
+	first := c.count == 0
+	c.count++
+	if n := utf8.RuneCountInString(s); n > c.maxLength {
+		c.maxLength = n
+	}
+	if first || s < c.minString {
+		c.minString = s
+	}
+	if first || s > c.maxString {
+		c.maxString = s
+	}
+
+	if first || c.isNumber {
+		n, err := toNumber(s)
+		c.isNumber = err == nil
+		if c.isNumber {
+			if _, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err != nil {
+				c.isInt = false
+			} else if first {
+				c.isInt = true
+			}
+			c.sum += n
+			if first || n < c.minNumber {
+				c.minNumber = n
+			}
+			if first || n > c.maxNumber {
+				c.maxNumber = n
+			}
+		}
+	}
+	if first || c.isDate {
+		t, err := toDate(s)
+		c.isDate = err == nil
+		if c.isDate {
+			if first || t.Before(c.minDate) {
+				c.minDate = t
+			}
+			if first || t.After(c.maxDate) {
+				c.maxDate = t
+			}
+		}
+	}
+}
+
+func (c *columnStats) typeName() string {
+	switch {
+	case c.count == 0:
+		return "empty"
+	case c.isNumber && c.isInt:
+		return "int"
+	case c.isNumber:
+		return "float"
+	case c.isDate:
+		return "date"
+	}
+	return "string"
+}
+
+func (c *columnStats) record(header string, topN int) []string {
+	var min, max, mean string
+	switch {
+	case c.count == 0:
+	case c.isNumber:
+		min = formatNumber(c.minNumber)
+		max = formatNumber(c.maxNumber)
+		mean = formatNumber(c.sum / float64(c.count))
+	case c.isDate:
+		min = c.minDate.Format(time.RFC3339)
+		max = c.maxDate.Format(time.RFC3339)
+	default:
+		min = c.minString
+		max = c.maxString
+	}
+
+	top := make([]string, 0)
+	for _, vc := range c.counter.top(topN) {
+		top = append(top, fmt.Sprintf("%s (%d)", vc.value, vc.count))
+	}
+
+	return []string{
+		header,
+		c.typeName(),
+		strconv.Itoa(c.count),
+		strconv.Itoa(c.counter.distinct()),
+		min,
+		max,
+		mean,
+		strconv.Itoa(c.maxLength),
+		strings.Join(top, ", "),
+	}
+}
+
+var statsHeaders = []string{
+	"header",
+	"type",
+	"count",
+	"distinct",
+	"min",
+	"max",
+	"mean",
+	"max_length",
+	"top",
+}
+
+type Stats struct {
+	w             Writer
+	topN          int
+	approx        bool
+	headers       []string
+	columns       []*columnStats
+	parsedHeaders bool
+}
+
+func NewStats(w Writer, topN int, approx bool) *Stats {
+	return &Stats{
+		w:      w,
+		topN:   topN,
+		approx: approx,
+	}
+}
+
+func (s *Stats) newColumnStats() *columnStats {
+	c := &columnStats{}
+	if s.approx {
+		c.counter = newApproxCounter(100 + 10*s.topN)
+	} else {
+		c.counter = newExactCounter()
+	}
+	return c
+}
+
+func (s *Stats) WriteHeaders(headers []string) error {
+	if s.parsedHeaders {
+		return nil
+	}
+	s.headers = append([]string{}, headers...)
+	s.columns = make([]*columnStats, len(headers))
+	for i := range s.columns {
+		s.columns[i] = s.newColumnStats()
+	}
+	s.parsedHeaders = true
+	return s.w.WriteHeaders(statsHeaders)
+}
+
+func (s *Stats) Write(record []string) error {
+	for len(s.columns) < len(record) {
+		s.headers = append(s.headers, "")
+		s.columns = append(s.columns, s.newColumnStats())
+	}
+	for i, field := range record {
+		s.columns[i].add(field)
+	}
+	return nil
+}
+
+func (s *Stats) Flush() error {
+	for i, c := range s.columns {
+		if err := s.w.Write(c.record(s.headers[i], s.topN)); err != nil {
+			if err == errStop {
+				break
+			}
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+func (s *Stats) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	headers := []string{"name", "price", "ratio", "date", "note"}
+	src := [][]string{
+		{"Apple", "60", "0.5", "2016-02-01", ""},
+		{"Grapes", "140", "1", "2015-12-24", ""},
+		{"Apple", "400", "1.5", "2016-01-10", ""},
+		{"Pineapple", "", "2", "2015-11-30", ""},
+	}
+	w := &DummyWriter{}
+	s := NewStats(w, 2, false)
+	if err := s.WriteHeaders(headers); err != nil {
+		t.Fatalf("WriteHeaders(%q) returns %q, want nil", headers, err)
+	}
+	for _, record := range src {
+		if err := s.Write(record); err != nil {
+			t.Fatalf("Write(%q) returns %q, want nil", record, err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() returns %q, want nil", err)
+	}
+
+	if !reflect.DeepEqual(w.headers, [][]string{statsHeaders}) {
+		t.Errorf("headers = %q, want %q", w.headers, [][]string{statsHeaders})
+	}
+	expect := [][]string{
+		{"name", "string", "4", "3", "Apple", "Pineapple", "", "9", "Apple (2), Grapes (1)"},
+		{"price", "int", "3", "3", "60", "400", "200", "3", "60 (1), 140 (1)"},
+		{"ratio", "float", "4", "4", "0.5", "2", "1.25", "3", "0.5 (1), 1 (1)"},
+		{"date", "date", "4", "4", "2015-11-30T00:00:00Z", "2016-02-01T00:00:00Z", "", "10", "2016-02-01 (1), 2015-12-24 (1)"},
+		{"note", "empty", "0", "0", "", "", "", "0", ""},
+	}
+	actual := w.records
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("got:\n%s\nwant:\n%s", toLines(actual), toLines(expect))
+	}
+}
+
+func TestApproxCounter(t *testing.T) {
+	c := newApproxCounter(10)
+	n := 100000
+	for i := 0; i < n; i++ {
+		c.add(strconv.Itoa(i))
+		c.add("frequent")
+	}
+
+	if d := c.distinct(); math.Abs(float64(d-n-1)) > float64(n)*0.03 {
+		t.Errorf("distinct() = %d, want about %d", d, n+1)
+	}
+	if top := c.top(1); len(top) != 1 || top[0].value != "frequent" || top[0].count != n {
+		t.Errorf("top(1) = %v, want [{frequent %d}]", top, n)
+	}
+	if top := c.top(20); len(top) != 10 {
+		t.Errorf("len(top(20)) = %d, want 10", len(top))
+	}
+}
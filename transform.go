@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+// unescapeSeparators removes backslashes only in front of seps,
+// to keep other backslashes for regular expressions.
+func unescapeSeparators(s string, seps string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if strings.IndexByte(seps, s[i+1]) != -1 {
+				i++
+			} else {
+				b = append(b, s[i])
+				i++
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// splitOnce splits s at the first sep which is not escaped by a backslash.
+func splitOnce(s string, sep rune) (string, string, bool) {
+	a := splitList(s, sep)
+	if len(a) < 2 {
+		return s, "", false
+	}
+	return a[0], s[len(a[0])+utf8.RuneLen(sep):], true
+}
+
+type valueFunc func(s string) (string, error)
+
+func toWidth(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q: invalid width", s)
+	}
+	return n, nil
+}
+
+func padFunc(args []string, left bool) (valueFunc, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("pad requires WIDTH[:CHAR]")
+	}
+	width, err := toWidth(args[0])
+	if err != nil {
+		return nil, err
+	}
+	ch := " "
+	if len(args) == 2 {
+		if utf8.RuneCountInString(args[1]) != 1 {
+			return nil, fmt.Errorf("%q: the pad must be a single character", args[1])
+		}
+		ch = args[1]
+	}
+	return func(s string) (string, error) {
+		n := width - utf8.RuneCountInString(s)
+		if n <= 0 {
+			return s, nil
+		}
+		if left {
+			return strings.Repeat(ch, n) + s, nil
+		}
+		return s + strings.Repeat(ch, n), nil
+	}, nil
+}
+
+var exprSubstitute = regexp.MustCompile(`^s/((?:[^/\\]|\\.)*)/((?:[^/\\]|\\.)*)/$`)
+
+func substituteFunc(s string) (valueFunc, error) {
+	m := exprSubstitute.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("%q: invalid substitution", s)
+	}
+	re, err := regexp.Compile(strings.Replace(m[1], `\/`, `/`, -1))
+	if err != nil {
+		return nil, err
+	}
+	repl := strings.Replace(m[2], `\/`, `/`, -1)
+	return func(s string) (string, error) {
+		return re.ReplaceAllString(s, repl), nil
+	}, nil
+}
+
+func parseValueFunc(s string) (valueFunc, error) {
+	if strings.HasPrefix(s, "s/") {
+		return substituteFunc(unescapeSeparators(s, ",|="))
+	}
+
+	a := splitList(s, ':')
+	name, args := a[0], a[1:]
+	for i := range args {
+		args[i] = unescapeSeparators(args[i], ",|=:")
+	}
+	switch name {
+	case "trim", "upper", "lower":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("%s takes no arguments", name)
+		}
+	}
+
+	switch name {
+	case "trim":
+		return func(s string) (string, error) {
+			return strings.TrimSpace(s), nil
+		}, nil
+	case "upper":
+		return func(s string) (string, error) {
+			return strings.ToUpper(s), nil
+		}, nil
+	case "lower":
+		return func(s string) (string, error) {
+			return strings.ToLower(s), nil
+		}, nil
+	case "substr":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("substr requires START[:LENGTH]")
+		}
+		start, err := csvp.ParseIndex(args[0])
+		if err != nil {
+			return nil, err
+		}
+		length := -1
+		if len(args) == 2 {
+			if length, err = toWidth(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		return func(s string) (string, error) {
+			a := []rune(s)
+			if start > len(a) {
+				return "", nil
+			}
+			a = a[start-1:]
+			if length >= 0 && length < len(a) {
+				a = a[:length]
+			}
+			return string(a), nil
+		}, nil
+	case "pad":
+		return padFunc(args, false)
+	case "lpad":
+		return padFunc(args, true)
+	case "default":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("default requires VALUE")
+		}
+		value := args[0]
+		return func(s string) (string, error) {
+			if s == "" {
+				return value, nil
+			}
+			return s, nil
+		}, nil
+	case "number":
+		if len(args) > 1 {
+			return nil, fmt.Errorf("number takes at most DECIMALS")
+		}
+		decimals := -1
+		if len(args) == 1 {
+			var err error
+			if decimals, err = toWidth(args[0]); err != nil {
+				return nil, err
+			}
+		}
+		return func(s string) (string, error) {
+			if s == "" {
+				return s, nil
+			}
+			n, err := toNumber(s)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(n, 'f', decimals, 64), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("%q: unknown function", name)
+}
+
+type mapping struct {
+	header string
+	index  int
+	funcs  []valueFunc
+}
+
+// Mapper transforms values of the specified headers by functions.
+type Mapper struct {
+	mappings []*mapping
+}
+
+func NewMapper(list string) (*Mapper, error) {
+	m := &Mapper{}
+	for _, item := range splitList(list, ',') {
+		header, rawFuncs, ok := splitOnce(item, '=')
+		if !ok {
+			return nil, fmt.Errorf("%q: invalid mapping", item)
+		}
+
+		mp := &mapping{header: csvp.Unescape(header)}
+		for _, rawFunc := range splitList(rawFuncs, '|') {
+			f, err := parseValueFunc(rawFunc)
+			if err != nil {
+				return nil, err
+			}
+			mp.funcs = append(mp.funcs, f)
+		}
+		m.mappings = append(m.mappings, mp)
+	}
+	return m, nil
+}
+
+func (m *Mapper) ParseHeaders(headers []string) ([]string, error) {
+	for _, mp := range m.mappings {
+		mp.index = headerIndex(headers, mp.header)
+		if mp.index == -1 {
+			return nil, fmt.Errorf("%q: no such header", mp.header)
+		}
+	}
+	return headers, nil
+}
+
+// Transform returns a copy of record with the values mapped,
+// so that the record of the scanner is not modified.
+func (m *Mapper) Transform(record []string) ([]string, error) {
+	record = append([]string(nil), record...)
+	for _, mp := range m.mappings {
+		if mp.index >= len(record) {
+			continue
+		}
+		s := record[mp.index]
+		for _, f := range mp.funcs {
+			var err error
+			if s, err = f(s); err != nil {
+				return nil, fmt.Errorf("%s: %s", mp.header, err)
+			}
+		}
+		record[mp.index] = s
+	}
+	return record, nil
+}
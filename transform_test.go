@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var unescapeSeparatorsTests = []struct {
+	src  string
+	seps string
+	dst  string
+}{
+	{src: `a\,b`, seps: ",", dst: "a,b"},
+	{src: `\d+\|x`, seps: ",|", dst: `\d+|x`},
+	{src: `a\\,b`, seps: ",", dst: `a\\,b`},
+	{src: `a\`, seps: ",", dst: `a\`},
+}
+
+func TestUnescapeSeparators(t *testing.T) {
+	for _, test := range unescapeSeparatorsTests {
+		expect := test.dst
+		actual := unescapeSeparators(test.src, test.seps)
+		if actual != expect {
+			t.Errorf("unescapeSeparators(%q, %q) = %q, want %q",
+				test.src, test.seps, actual, expect)
+		}
+	}
+}
+
+var valueFuncTests = []struct {
+	function string
+	src      string
+	dst      string
+}{
+	{function: "trim", src: "  a b  ", dst: "a b"},
+	{function: "upper", src: "Apple", dst: "APPLE"},
+	{function: "lower", src: "Apple", dst: "apple"},
+	{function: `s/(\d+)-(\d+)/$2-$1/`, src: "10-20", dst: "20-10"},
+	{function: `s/a\/b/c/`, src: "a/b/a/b", dst: "c/c"},
+	{function: "substr:2", src: "Apple", dst: "pple"},
+	{function: "substr:2:3", src: "Apple", dst: "ppl"},
+	{function: "substr:9", src: "Apple", dst: ""},
+	{function: "substr:1:2", src: "りんご", dst: "りん"},
+	{function: "pad:7", src: "Apple", dst: "Apple  "},
+	{function: "lpad:5:0", src: "42", dst: "00042"},
+	{function: "lpad:2", src: "Apple", dst: "Apple"},
+	{function: "default:N/A", src: "", dst: "N/A"},
+	{function: "default:N/A", src: "x", dst: "x"},
+	{function: `default:a\:b`, src: "", dst: "a:b"},
+	{function: "number", src: " 1.50 ", dst: "1.5"},
+	{function: "number:2", src: "3", dst: "3.00"},
+	{function: "number:0", src: "2.6", dst: "3"},
+	{function: "number", src: "", dst: ""},
+}
+
+func TestValueFunc(t *testing.T) {
+	for _, test := range valueFuncTests {
+		f, err := parseValueFunc(test.function)
+		if err != nil {
+			t.Errorf("parseValueFunc(%q) returns %q, want nil",
+				test.function, err)
+			continue
+		}
+		expect := test.dst
+		actual, err := f(test.src)
+		if err != nil {
+			t.Errorf("%s(%q) returns %q, want nil",
+				test.function, test.src, err)
+			continue
+		}
+		if actual != expect {
+			t.Errorf("%s(%q) = %q, want %q",
+				test.function, test.src, actual, expect)
+		}
+	}
+}
+
+func TestValueFuncError(t *testing.T) {
+	for _, function := range []string{"foo", "trim:1", "substr", "substr:0", "pad:x", "lpad:3:ab", "default", "number:a", "s/(/x/"} {
+		if _, err := parseValueFunc(function); err == nil {
+			t.Errorf("parseValueFunc(%q) returns nil, want err", function)
+		}
+	}
+
+	f, _ := parseValueFunc("number")
+	if _, err := f("abc"); err == nil {
+		t.Errorf("number(%q) returns nil, want err", "abc")
+	}
+}
+
+func TestMapper(t *testing.T) {
+	m, err := NewMapper("name=trim|upper,price=number:2")
+	if err != nil {
+		t.Fatalf("NewMapper() returns %q, want nil", err)
+	}
+	headers := []string{"name", "price", "quantity"}
+	if _, err = m.ParseHeaders(headers); err != nil {
+		t.Fatalf("ParseHeaders(%q) returns %q, want nil", headers, err)
+	}
+
+	expect := []string{"APPLE", "60.00", " 20 "}
+	record := []string{" apple ", "60", " 20 "}
+	actual, err := m.Transform(record)
+	if err != nil {
+		t.Fatalf("Transform() returns %q, want nil", err)
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("Transform() = %q, want %q", actual, expect)
+	}
+	if source := []string{" apple ", "60", " 20 "}; !reflect.DeepEqual(record, source) {
+		t.Errorf("Transform() modifies the record to %q, want %q", record, source)
+	}
+
+	if _, err = m.ParseHeaders([]string{"price"}); err == nil {
+		t.Errorf("ParseHeaders(%q) returns nil, want err", []string{"price"})
+	}
+	if _, err = NewMapper("name"); err == nil {
+		t.Errorf("NewMapper(%q) returns nil, want err", "name")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+)
+
+const defaultTransposeBufferSize = 64 << 20
+
+type Transposer struct {
+	w             Writer
+	withHeaders   bool
+	bufferSize    int
+	size          int
+	records       [][]string
+	parsedHeaders bool
+}
+
+func NewTransposer(w Writer, withHeaders bool) *Transposer {
+	return &Transposer{
+		w:           w,
+		withHeaders: withHeaders,
+		bufferSize:  defaultTransposeBufferSize,
+	}
+}
+
+func (t *Transposer) SetBufferSize(size int) {
+	t.bufferSize = size
+}
+
+func (t *Transposer) WriteHeaders(headers []string) error {
+	if t.parsedHeaders || !t.withHeaders {
+		return nil
+	}
+	t.parsedHeaders = true
+	return t.Write(headers)
+}
+
+func (t *Transposer) Write(record []string) error {
+	t.size += recordSize(record)
+	if t.size > t.bufferSize {
+		return fmt.Errorf("too large to transpose (more than %d bytes)", t.bufferSize)
+	}
+	t.records = append(t.records, record)
+	return nil
+}
+
+func (t *Transposer) Flush() error {
+	width := 0
+	for _, record := range t.records {
+		if len(record) > width {
+			width = len(record)
+		}
+	}
+
+	for i := 0; i < width; i++ {
+		a := make([]string, len(t.records))
+		for j, record := range t.records {
+			if i < len(record) {
+				a[j] = record[i]
+			}
+		}
+		if err := t.w.Write(a); err != nil {
+			return err
+		}
+	}
+	t.records = nil
+	return t.w.Flush()
+}
+
+func (t *Transposer) Close() error {
+	return t.w.Close()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var transposerTests = []struct {
+	withHeaders bool
+	headers     []string
+	src         [][]string
+	dst         [][]string
+}{
+	{
+		withHeaders: true,
+		headers:     []string{"name", "price", "quantity"},
+		src: [][]string{
+			{"Apple", "60", "20"},
+		},
+		dst: [][]string{
+			{"name", "Apple"},
+			{"price", "60"},
+			{"quantity", "20"},
+		},
+	},
+	{
+		withHeaders: false,
+		headers:     []string{"name", "price", "quantity"},
+		src: [][]string{
+			{"Apple", "60", "20"},
+			{"Grapes", "140", "8"},
+		},
+		dst: [][]string{
+			{"Apple", "Grapes"},
+			{"60", "140"},
+			{"20", "8"},
+		},
+	},
+	{
+		withHeaders: false,
+		headers:     []string{"a", "b", "c"},
+		src: [][]string{
+			{"1"},
+			{"2", "3", "4"},
+		},
+		dst: [][]string{
+			{"1", "2"},
+			{"", "3"},
+			{"", "4"},
+		},
+	},
+}
+
+func TestTransposer(t *testing.T) {
+	for _, test := range transposerTests {
+		w := &DummyWriter{}
+		tr := NewTransposer(w, test.withHeaders)
+		self := fmt.Sprintf("{withHeaders=%v, src=%q}", test.withHeaders, test.src)
+		if err := tr.WriteHeaders(test.headers); err != nil {
+			t.Errorf("%s.WriteHeaders(%q) returns %q, want nil",
+				self, test.headers, err)
+			continue
+		}
+		for _, record := range test.src {
+			if err := tr.Write(record); err != nil {
+				t.Errorf("%s.Write(%q) returns %q, want nil",
+					self, record, err)
+			}
+		}
+		if err := tr.Flush(); err != nil {
+			t.Errorf("%s.Flush() returns %q, want nil", self, err)
+		}
+
+		expect := test.dst
+		actual := w.records
+		if !reflect.DeepEqual(actual, expect) {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s",
+				self, toLines(actual), toLines(expect))
+		}
+	}
+}
+
+func TestTransposerBufferSize(t *testing.T) {
+	tr := NewTransposer(&DummyWriter{}, false)
+	tr.SetBufferSize(100)
+	var err error
+	for i := 0; err == nil && i < 10; i++ {
+		err = tr.Write([]string{"aaaaaaaaaa", "bbbbbbbbbb"})
+	}
+	if err == nil {
+		t.Errorf("Write() over the buffer size returns nil, want err")
+	}
+}
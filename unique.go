@@ -0,0 +1,153 @@
+package main
+
+import (
+	"container/list"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/nil-two/csvp/csvp"
+)
+
+type uniqueEntry struct {
+	record []string
+	count  int
+}
+
+// Unique drops records which have the same key as a previous record.
+// In keep-last or count mode, it keeps one entry for each key
+// in the order of printing.
+type Unique struct {
+	w             Writer
+	by            *csvp.Headers
+	keepLast      bool
+	count         bool
+	hash          bool
+	seen          map[string]*list.Element
+	entries       *list.List
+	parsedHeaders bool
+}
+
+// NewUnique returns a Unique which identifies records by headers in by,
+// or by whole records if by is empty.
+func NewUnique(w Writer, by string) *Unique {
+	u := &Unique{
+		w:       w,
+		seen:    make(map[string]*list.Element),
+		entries: list.New(),
+	}
+	if by != "" {
+		u.by = csvp.NewHeaders(by)
+	}
+	return u
+}
+
+// SetKeepLast makes u print the last record for each key
+// instead of the first one.
+func (u *Unique) SetKeepLast(keepLast bool) {
+	u.keepLast = keepLast
+}
+
+// SetCount makes u print the number of records for each key
+// in front of each record.
+func (u *Unique) SetCount(count bool) {
+	u.count = count
+}
+
+// SetHash makes u remember keys by their 64-bit hashes
+// to use bounded memory per key.
+// In keep-last or count mode, u still keeps a record for each key.
+// Different keys are regarded as the same in rare cases of collision.
+func (u *Unique) SetHash(hash bool) {
+	u.hash = hash
+}
+
+func (u *Unique) buffered() bool {
+	return u.keepLast || u.count
+}
+
+func (u *Unique) WriteHeaders(headers []string) error {
+	if u.by != nil {
+		if err := u.by.ParseHeaders(headers); err != nil {
+			return err
+		}
+		if err := checkHeaders(u.by); err != nil {
+			return err
+		}
+	}
+	if u.parsedHeaders {
+		return nil
+	}
+	u.parsedHeaders = true
+	if u.count {
+		headers = append([]string{"count"}, headers...)
+	}
+	return u.w.WriteHeaders(headers)
+}
+
+func (u *Unique) key(record []string) (string, error) {
+	key := record
+	if u.by != nil {
+		var err error
+		key, err = u.by.Select(record)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	k := recordKey(key)
+	if u.hash {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, hash64(k))
+		k = string(b)
+	}
+	return k, nil
+}
+
+func (u *Unique) Write(record []string) error {
+	k, err := u.key(record)
+	if err != nil {
+		return err
+	}
+
+	el, ok := u.seen[k]
+	switch {
+	case !u.buffered():
+		if ok {
+			return nil
+		}
+		u.seen[k] = nil
+		return u.w.Write(record)
+	case !ok:
+		u.seen[k] = u.entries.PushBack(&uniqueEntry{record: record, count: 1})
+	default:
+		e := el.Value.(*uniqueEntry)
+		e.count++
+		if u.keepLast {
+			e.record = record
+			u.entries.MoveToBack(el)
+		}
+	}
+	return nil
+}
+
+func (u *Unique) Flush() error {
+	for el := u.entries.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*uniqueEntry)
+		record := e.record
+		if u.count {
+			record = append([]string{strconv.Itoa(e.count)}, record...)
+		}
+		if err := u.w.Write(record); err != nil {
+			if err == errStop {
+				break
+			}
+			return err
+		}
+	}
+	u.entries.Init()
+	return u.w.Flush()
+}
+
+func (u *Unique) Close() error {
+	return u.w.Close()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var uniqueHeaders = []string{"email", "name"}
+
+var uniqueItems = [][]string{
+	{"a@example.com", "Alice"},
+	{"b@example.com", "Bob"},
+	{"a@example.com", "Alice"},
+	{"c@example.com", "Carol"},
+	{"b@example.com", "Robert"},
+}
+
+var uniqueTests = []struct {
+	list     string
+	keepLast bool
+	count    bool
+	headers  []string
+	dst      [][]string
+}{
+	{
+		list:    "",
+		headers: []string{"email", "name"},
+		dst: [][]string{
+			{"a@example.com", "Alice"},
+			{"b@example.com", "Bob"},
+			{"c@example.com", "Carol"},
+			{"b@example.com", "Robert"},
+		},
+	},
+	{
+		list:    "email",
+		headers: []string{"email", "name"},
+		dst: [][]string{
+			{"a@example.com", "Alice"},
+			{"b@example.com", "Bob"},
+			{"c@example.com", "Carol"},
+		},
+	},
+	{
+		list:     "email",
+		keepLast: true,
+		headers:  []string{"email", "name"},
+		dst: [][]string{
+			{"a@example.com", "Alice"},
+			{"c@example.com", "Carol"},
+			{"b@example.com", "Robert"},
+		},
+	},
+	{
+		list:    "email",
+		count:   true,
+		headers: []string{"count", "email", "name"},
+		dst: [][]string{
+			{"2", "a@example.com", "Alice"},
+			{"2", "b@example.com", "Bob"},
+			{"1", "c@example.com", "Carol"},
+		},
+	},
+	{
+		list:     "name",
+		keepLast: true,
+		count:    true,
+		headers:  []string{"count", "email", "name"},
+		dst: [][]string{
+			{"1", "b@example.com", "Bob"},
+			{"2", "a@example.com", "Alice"},
+			{"1", "c@example.com", "Carol"},
+			{"1", "b@example.com", "Robert"},
+		},
+	},
+}
+
+func TestUnique(t *testing.T) {
+	for _, hash := range []bool{false, true} {
+		for _, test := range uniqueTests {
+			w := &DummyWriter{}
+			u := NewUnique(w, test.list)
+			u.SetKeepLast(test.keepLast)
+			u.SetCount(test.count)
+			u.SetHash(hash)
+			self := fmt.Sprintf("{list=%q, keepLast=%v, count=%v, hash=%v}",
+				test.list, test.keepLast, test.count, hash)
+
+			if err := u.WriteHeaders(uniqueHeaders); err != nil {
+				t.Errorf("%s.WriteHeaders(%q) returns %q, want nil",
+					self, uniqueHeaders, err)
+				continue
+			}
+			for _, record := range uniqueItems {
+				if err := u.Write(record); err != nil {
+					t.Errorf("%s.Write(%q) returns %q, want nil",
+						self, record, err)
+				}
+			}
+			if err := u.Flush(); err != nil {
+				t.Errorf("%s.Flush() returns %q, want nil", self, err)
+			}
+
+			if !reflect.DeepEqual(w.headers, [][]string{test.headers}) {
+				t.Errorf("%s: headers = %q, want %q",
+					self, w.headers, [][]string{test.headers})
+			}
+			expect := test.dst
+			actual := w.records
+			if !reflect.DeepEqual(actual, expect) {
+				t.Errorf("%s:\ngot:\n%s\nwant:\n%s",
+					self, toLines(actual), toLines(expect))
+			}
+		}
+	}
+}
+
+func TestUniqueHeadersOnce(t *testing.T) {
+	for _, keepLast := range []bool{false, true} {
+		for _, count := range []bool{false, true} {
+			w := &DummyWriter{}
+			u := NewUnique(w, "email")
+			u.SetKeepLast(keepLast)
+			u.SetCount(count)
+			self := fmt.Sprintf("{keepLast=%v, count=%v}", keepLast, count)
+
+			for _, headers := range [][]string{uniqueHeaders, {"name", "email"}} {
+				if err := u.WriteHeaders(headers); err != nil {
+					t.Errorf("%s.WriteHeaders(%q) returns %q, want nil",
+						self, headers, err)
+				}
+			}
+			u.Write([]string{"Alice", "a@example.com"})
+			u.Write([]string{"Alicia", "a@example.com"})
+			u.Flush()
+
+			expect := [][]string{uniqueHeaders}
+			if count {
+				expect = [][]string{append([]string{"count"}, uniqueHeaders...)}
+			}
+			if !reflect.DeepEqual(w.headers, expect) {
+				t.Errorf("%s: headers = %q, want %q", self, w.headers, expect)
+			}
+			if len(w.records) != 1 {
+				t.Errorf("%s: got %d records, want 1", self, len(w.records))
+			}
+		}
+	}
+}
+
+func TestUniqueKeepLastEntries(t *testing.T) {
+	w := &DummyWriter{}
+	u := NewUnique(w, "email")
+	u.SetKeepLast(true)
+	u.WriteHeaders(uniqueHeaders)
+	for i := 0; i < 1000; i++ {
+		u.Write([]string{"a@example.com", fmt.Sprint("Alice", i)})
+		u.Write([]string{"b@example.com", fmt.Sprint("Bob", i)})
+	}
+	if n := u.entries.Len(); n != 2 {
+		t.Errorf("got %d entries, want 2", n)
+	}
+	u.Flush()
+
+	expect := [][]string{
+		{"a@example.com", "Alice999"},
+		{"b@example.com", "Bob999"},
+	}
+	if !reflect.DeepEqual(w.records, expect) {
+		t.Errorf("got:\n%s\nwant:\n%s", toLines(w.records), toLines(expect))
+	}
+}
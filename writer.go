@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// errStop is returned by Writer.Write when it needs no more records.
+// A Writer which writes its records in Flush stops writing at errStop,
+// and still flushes the next Writer.
+var errStop = errors.New("no more records needed")
+
+type Writer interface {
+	WriteHeaders(headers []string) error
+	Write(record []string) error
+	Flush() error
+	Close() error
+}
+
+// recordSize returns the approximate memory size of record in bytes.
+func recordSize(record []string) int {
+	size := 24 + 16*len(record)
+	for _, field := range record {
+		size += len(field)
+	}
+	return size
+}
+
+// Printer writes records to w through a buffer.
+// Buffered records are written to w by Flush or Close.
+type Printer struct {
+	w               *bufio.Writer
+	outputDelimiter string
+	printHeaders    bool
+}
+
+func NewPrinter(w io.Writer, outputDelimiter string, printHeaders bool) *Printer {
+	return &Printer{
+		w:               bufio.NewWriterSize(w, 64<<10),
+		outputDelimiter: outputDelimiter,
+		printHeaders:    printHeaders,
+	}
+}
+
+func (p *Printer) WriteHeaders(headers []string) error {
+	if !p.printHeaders {
+		return nil
+	}
+	return p.Write(headers)
+}
+
+func (p *Printer) Write(record []string) error {
+	for i, field := range record {
+		if i > 0 {
+			p.w.WriteString(p.outputDelimiter)
+		}
+		p.w.WriteString(field)
+	}
+	return p.w.WriteByte('\n')
+}
+
+func (p *Printer) Flush() error {
+	return p.w.Flush()
+}
+
+// Close writes the buffered records,
+// so that the output before an error is not lost.
+func (p *Printer) Close() error {
+	return p.w.Flush()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func toLines(cells [][]string) string {
+	lines := make([]string, len(cells))
+	for i, line := range cells {
+		lines[i] = fmt.Sprintf("%q", line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+type DummyWriter struct {
+	headers [][]string
+	records [][]string
+	flushed bool
+	closed  bool
+}
+
+func (d *DummyWriter) WriteHeaders(headers []string) error {
+	d.headers = append(d.headers, headers)
+	return nil
+}
+
+func (d *DummyWriter) Write(record []string) error {
+	d.records = append(d.records, record)
+	return nil
+}
+
+func (d *DummyWriter) Flush() error {
+	d.flushed = true
+	return nil
+}
+
+func (d *DummyWriter) Close() error {
+	d.closed = true
+	return nil
+}
+
+var printerTests = []struct {
+	outputDelimiter string
+	printHeaders    bool
+	headers         []string
+	src             [][]string
+	dst             string
+}{
+	{
+		outputDelimiter: "\t",
+		printHeaders:    false,
+		headers:         []string{"name", "price"},
+		src: [][]string{
+			{"Apple", "60"},
+			{"Grapes", "140"},
+		},
+		dst: "Apple\t60\nGrapes\t140\n",
+	},
+	{
+		outputDelimiter: ",",
+		printHeaders:    true,
+		headers:         []string{"name", "price"},
+		src: [][]string{
+			{"Apple", "60"},
+			{"Grapes", "140"},
+		},
+		dst: "name,price\nApple,60\nGrapes,140\n",
+	},
+}
+
+func TestPrinter(t *testing.T) {
+	for _, test := range printerTests {
+		b := &bytes.Buffer{}
+		p := NewPrinter(b, test.outputDelimiter, test.printHeaders)
+		if err := p.WriteHeaders(test.headers); err != nil {
+			t.Errorf("WriteHeaders(%q) returns %q, want nil",
+				test.headers, err)
+			continue
+		}
+		for _, record := range test.src {
+			if err := p.Write(record); err != nil {
+				t.Errorf("Write(%q) returns %q, want nil",
+					record, err)
+			}
+		}
+		if err := p.Flush(); err != nil {
+			t.Errorf("Flush() returns %q, want nil", err)
+		}
+
+		expect := test.dst
+		actual := b.String()
+		if actual != expect {
+			t.Errorf("printHeaders=%v:\ngot:\n%s\nwant:\n%s",
+				test.printHeaders, actual, expect)
+		}
+	}
+}
+
+func TestPrinterFlush(t *testing.T) {
+	b := &bytes.Buffer{}
+	p := NewPrinter(b, ",", true)
+	if err := p.Write([]string{"a", "b"}); err != nil {
+		t.Fatalf("Write() returns %q, want nil", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Write() writes %q before Flush(), want buffered", b.String())
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Flush() returns %q, want nil", err)
+	}
+	if expect, actual := "a,b\n", b.String(); actual != expect {
+		t.Errorf("got %q after Flush(), want %q", actual, expect)
+	}
+}
+
+func BenchmarkPrinter(b *testing.B) {
+	records := make([][]string, 10000)
+	size := 0
+	for i := range records {
+		records[i] = []string{fmt.Sprint(i), fmt.Sprintf("item%d", i), "2017-01-02"}
+		size += len(records[i][0]) + len(records[i][1]) + len(records[i][2]) + 3
+	}
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := NewPrinter(ioutil.Discard, "\t", true)
+		for _, record := range records {
+			if err := p.Write(record); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := p.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}